@@ -0,0 +1,243 @@
+package json
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ChangeKind is the kind of a Change.
+type ChangeKind int
+
+const (
+	Added ChangeKind = iota
+	Removed
+	Changed
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	}
+	return "changed"
+}
+
+// Change is a difference found by Compare. Old is nil for an added value and
+// New for a removed one.
+type Change struct {
+	Kind     ChangeKind
+	Path     Pointer
+	Old, New any
+}
+
+// MarshalJSON writes the change as an object with the members kind, path, old
+// and new, leaving out old for an added value and new for a removed one.
+func (c Change) MarshalJSON() ([]byte, error) {
+	var b strings.Builder
+	b.WriteString(`{"kind":` + quote(c.Kind.String(), false) + `,"path":` + quote(c.Path.String(), false))
+	if c.Kind != Added {
+		old, err := MarshalE(c.Old)
+		if err != nil {
+			return nil, err
+		}
+		b.WriteString(`,"old":` + old)
+	}
+	if c.Kind != Removed {
+		value, err := MarshalE(c.New)
+		if err != nil {
+			return nil, err
+		}
+		b.WriteString(`,"new":` + value)
+	}
+	b.WriteString("}")
+	return []byte(b.String()), nil
+}
+
+// Changes lists the differences between two containers, in document order.
+type Changes []Change
+
+// Unified renders the changes for people, in the manner of a unified diff: a
+// line starting with '-' for each removed or old value and '+' for each added
+// or new one, after the pointer to the value.
+func (changes Changes) Unified() string {
+	var b strings.Builder
+	for _, c := range changes {
+		if c.Kind != Added {
+			fmt.Fprintf(&b, "- %s: %s\n", c.Path, Marshal(c.Old))
+		}
+		if c.Kind != Removed {
+			fmt.Fprintf(&b, "+ %s: %s\n", c.Path, Marshal(c.New))
+		}
+	}
+	return b.String()
+}
+
+// JSON renders the changes for programs, as a JSON array of the objects
+// written by Change.MarshalJSON.
+func (changes Changes) JSON() string {
+	return Marshal(append(Changes{}, changes...))
+}
+
+// CompareOption configures Compare.
+type CompareOption func(*compareState)
+
+// MatchKey matches the elements of arrays of objects by the value of their
+// member field instead of by position, for arrays whose elements all have
+// it, so that a reordered element is not reported as changed.
+func MatchKey(field string) CompareOption {
+	return func(s *compareState) {
+		s.key = field
+	}
+}
+
+// Compare returns the differences between a and b. Members of objects are
+// matched by name. Elements of arrays are matched along their longest common
+// subsequence, and unmatched elements between two matches compared by
+// position; MatchKey matches them by a key member instead. The path of an
+// added or matched element has its index in b, the path of a removed one its
+// index in a.
+//
+// Unlike Diff, which returns a patch to apply, Compare describes the changes
+// for display and review.
+func Compare(a, b *Container, opts ...CompareOption) Changes {
+	s := &compareState{changes: Changes{}}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	d := &differ{
+		arrays: s.matchArrays,
+		removed: func(path Pointer, old any) {
+			s.add(Removed, path, old, nil)
+		},
+		added: func(path Pointer, value any) {
+			s.add(Added, path, nil, value)
+		},
+		changed: func(path Pointer, old, value any) {
+			s.add(Changed, path, old, value)
+		},
+	}
+	d.walk(Pointer{}, a.Data(), b.Data())
+	return s.changes
+}
+
+type compareState struct {
+	key     string
+	changes Changes
+}
+
+func (s *compareState) add(kind ChangeKind, path Pointer, old, new any) {
+	s.changes = append(s.changes, Change{Kind: kind, Path: path, Old: deepCopy(old), New: deepCopy(new)})
+}
+
+// matchArrays matches elements by their key member when MatchKey gives one
+// that both arrays have, and along their longest common subsequence
+// otherwise.
+func (s *compareState) matchArrays(d *differ, path Pointer, array, newArray []any) {
+	if s.keyed(array) && s.keyed(newArray) {
+		s.matchByKey(d, path, array, newArray)
+	} else {
+		matchBySequence(d, path, array, newArray)
+	}
+}
+
+// keyed reports whether all elements of array are objects with the key
+// member.
+func (s *compareState) keyed(array []any) bool {
+	if s.key == "" {
+		return false
+	}
+	for _, element := range array {
+		if _, ok := objectGet(element, s.key); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// matchByKey matches elements with equal key members.
+func (s *compareState) matchByKey(d *differ, path Pointer, array, newArray []any) {
+	matched := make([]bool, len(array))
+	find := func(key any) int {
+		for i, element := range array {
+			if value, _ := objectGet(element, s.key); !matched[i] && equalValues(value, key) {
+				return i
+			}
+		}
+		return -1
+	}
+
+	var added []int
+	for j, element := range newArray {
+		key, _ := objectGet(element, s.key)
+		if i := find(key); i >= 0 {
+			matched[i] = true
+			d.walk(pointerTo(path, j), array[i], element)
+		} else {
+			added = append(added, j)
+		}
+	}
+	for i, element := range array {
+		if !matched[i] {
+			d.removed(pointerTo(path, i), element)
+		}
+	}
+	for _, j := range added {
+		d.added(pointerTo(path, j), newArray[j])
+	}
+}
+
+// matchBySequence matches equal elements along the longest common
+// subsequence of the arrays. Between two matches, unmatched elements are
+// compared pairwise and the rest reported as removed or added.
+func matchBySequence(d *differ, path Pointer, array, newArray []any) {
+	n, m := len(array), len(newArray)
+
+	// lcs[i][j] is the length of the longest common subsequence of
+	// array[i:] and newArray[j:].
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			switch {
+			case equalValues(array[i], newArray[j]):
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	gap := func(i0, i, j0, j int) {
+		for ; i0 < i && j0 < j; i0, j0 = i0+1, j0+1 {
+			d.walk(pointerTo(path, j0), array[i0], newArray[j0])
+		}
+		for ; i0 < i; i0++ {
+			d.removed(pointerTo(path, i0), array[i0])
+		}
+		for ; j0 < j; j0++ {
+			d.added(pointerTo(path, j0), newArray[j0])
+		}
+	}
+
+	i0, j0, i, j := 0, 0, 0, 0
+	for i < n && j < m {
+		switch {
+		case equalValues(array[i], newArray[j]):
+			gap(i0, i, j0, j)
+			i, j = i+1, j+1
+			i0, j0 = i, j
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	gap(i0, n, j0, m)
+}
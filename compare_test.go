@@ -0,0 +1,69 @@
+package json
+
+import "testing"
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want string
+	}{
+		{`{"a":1}`, `{"a":1}`, ``},
+		{`{"a":1}`, `{"a":1.0}`, ``},
+		{`{"a":1,"b":2}`, `{"a":3,"c":4}`, "- /a: 1\n+ /a: 3\n- /b: 2\n+ /c: 4\n"},
+		{`{"a":{"b":[1,2]}}`, `{"a":{"b":"x"}}`, "- /a/b: [1,2]\n+ /a/b: \"x\"\n"},
+		{`{"a/b":1}`, `{"a/b":2}`, "- /a~1b: 1\n+ /a~1b: 2\n"},
+		{`[1,2,3]`, `[0,1,2,3]`, "+ /0: 0\n"},
+		{`[1,2,3]`, `[1,3]`, "- /1: 2\n"},
+		{`[1,2,3,4]`, `[1,5,3]`, "- /1: 2\n+ /1: 5\n- /3: 4\n"},
+		{`[{"id":1,"v":"a"},2]`, `[{"id":1,"v":"b"},2]`, "- /0/v: \"a\"\n+ /0/v: \"b\"\n"},
+		{`1`, `"1"`, "- : 1\n+ : \"1\"\n"},
+	}
+	for _, test := range tests {
+		if got := Compare(Parse(test.a), Parse(test.b)).Unified(); got != test.want {
+			t.Errorf("Compare(%s, %s) = %q, want %q", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestCompareMatchKey(t *testing.T) {
+	a := Parse(`{"users":[{"id":1,"name":"ann"},{"id":2,"name":"bob"},{"id":3,"name":"cy"}]}`)
+	b := Parse(`{"users":[{"id":3,"name":"cy"},{"id":1,"name":"anne"},{"id":4,"name":"dan"}]}`)
+
+	want := "- /users/1/name: \"ann\"\n+ /users/1/name: \"anne\"\n" +
+		"- /users/1: {\"id\":2,\"name\":\"bob\"}\n" +
+		"+ /users/2: {\"id\":4,\"name\":\"dan\"}\n"
+	if got := Compare(a, b, MatchKey("id")).Unified(); got != want {
+		t.Errorf("Compare with MatchKey = %q, want %q", got, want)
+	}
+
+	// Without the key the elements are matched by sequence.
+	if got := Compare(a, b).Unified(); got == want {
+		t.Errorf("Compare without MatchKey matched by key")
+	}
+
+	// Arrays whose elements lack the key fall back to sequences.
+	if got := Compare(Parse(`[1,2]`), Parse(`[2]`), MatchKey("id")).Unified(); got != "- /0: 1\n" {
+		t.Errorf("Compare with MatchKey of scalars = %q", got)
+	}
+}
+
+func TestCompareJSON(t *testing.T) {
+	changes := Compare(Parse(`{"a":1,"b":[1]}`), Parse(`{"a":2,"c":null}`))
+	if len(changes) != 3 {
+		t.Fatalf("Compare = %v, want 3 changes", changes)
+	}
+	if changes[0].Kind != Changed || changes[1].Kind != Removed || changes[2].Kind != Added {
+		t.Errorf("Compare kinds = %v %v %v", changes[0].Kind, changes[1].Kind, changes[2].Kind)
+	}
+
+	want := `[{"kind":"changed","path":"/a","old":1,"new":2},` +
+		`{"kind":"removed","path":"/b","old":[1]},` +
+		`{"kind":"added","path":"/c","new":null}]`
+	if got := changes.JSON(); got != want {
+		t.Errorf("JSON() = %s, want %s", got, want)
+	}
+
+	if got := Compare(Parse(`{}`), Parse(`{}`)).JSON(); got != `[]` {
+		t.Errorf("JSON() of no changes = %s, want []", got)
+	}
+}
@@ -3,6 +3,7 @@ package json
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -37,6 +38,39 @@ var (
 // Container references a specific element within a wrapped structure. See to gabs.
 type Container struct {
 	object any
+	doc    *document // source text, for a Container from ParseDocument
+
+	// A Container found inside a document by Search and the like edits it
+	// through root, the Container from ParseDocument, at path.
+	root *Container
+	path []string
+}
+
+// child returns a Container of object, found at hierarchy below g, that
+// belongs to the document of g if it has one.
+func (g *Container) child(object any, hierarchy ...string) *Container {
+	c := &Container{object: object}
+	switch {
+	case g.root != nil:
+		c.root, c.path = g.root, g.inDocument(hierarchy)
+	case g.doc != nil:
+		c.root, c.path = g, append([]string{}, hierarchy...)
+	}
+	return c
+}
+
+// inDocument returns the path of hierarchy below g from the root of its
+// document.
+func (g *Container) inDocument(hierarchy []string) []string {
+	return append(append([]string{}, g.path...), hierarchy...)
+}
+
+// refresh takes the value of g from its document again, after an edit that
+// may have replaced it.
+func (g *Container) refresh() {
+	if c, err := g.root.searchStrict(false, g.path...); err == nil {
+		g.object = c.object
+	}
 }
 
 func (g *Container) searchStrict(allowWildcard bool, hierarchy ...string) (*Container, error) {
@@ -44,10 +78,11 @@ func (g *Container) searchStrict(allowWildcard bool, hierarchy ...string) (*Cont
 
 	for target := 0; target < len(hierarchy); target++ {
 		pathSeg := hierarchy[target]
-		if mmap, ok := object.(map[string]any); ok {
-			object, ok = mmap[pathSeg]
+		if isObject(object) {
+			var ok bool
+			object, ok = objectGet(object, pathSeg)
 			if !ok {
-				return nil, fmt.Errorf("failed to resolve path segment '%v': key '%v' was not found", target, pathSeg)
+				return nil, fmt.Errorf("failed to resolve '%v': key '%v' was not found", pointerPrefix(hierarchy, target), pathSeg)
 			}
 
 		} else if marray, ok := object.([]any); ok {
@@ -63,25 +98,23 @@ func (g *Container) searchStrict(allowWildcard bool, hierarchy ...string) (*Cont
 				if len(tmpArray) == 0 {
 					return nil, nil
 				}
-				return &Container{tmpArray}, nil
+				// Not a value of the document, so not tied to it.
+				return &Container{object: tmpArray}, nil
 			}
-			index, err := strconv.Atoi(pathSeg)
+			index, err := arrayIndex(pathSeg)
 			if err != nil {
-				return nil, fmt.Errorf("failed to resolve path segment '%v': found array but segment value '%v' could not be parsed into array index: %v", target, pathSeg, err)
-			}
-			if index < 0 {
-				return nil, fmt.Errorf("failed to resolve path segment '%v': found array but index '%v' is invalid", target, pathSeg)
+				return nil, fmt.Errorf("failed to resolve '%v': found array but %v", pointerPrefix(hierarchy, target), err)
 			}
 			if len(marray) <= index {
-				return nil, fmt.Errorf("failed to resolve path segment '%v': found array but index '%v' exceeded target array size of '%v'", target, pathSeg, len(marray))
+				return nil, fmt.Errorf("failed to resolve '%v': found array but index '%v' exceeded target array size of '%v'", pointerPrefix(hierarchy, target), pathSeg, len(marray))
 			}
 			object = marray[index]
 		} else {
-			return nil, fmt.Errorf("failed to resolve path segment '%v': field '%v' was not found", target, pathSeg)
+			return nil, fmt.Errorf("failed to resolve '%v': field '%v' was not found", pointerPrefix(hierarchy, target), pathSeg)
 		}
 	}
 
-	return &Container{object}, nil
+	return g.child(object, hierarchy...), nil
 }
 
 // Search attempts to find and return an object within the wrapped structure by
@@ -116,22 +149,37 @@ func (g *Container) ExistPath(path string) bool {
 	return g.Exist(PathToSlice(path)...)
 }
 
+// IsNull checks whether a field exists within the hierarchy and holds a JSON
+// null.
+func (g *Container) IsNull(hierarchy ...string) bool {
+	c, err := g.searchStrict(true, hierarchy...)
+	return err == nil && c != nil && c.Data() == nil
+}
+
+// IsNullPath checks whether a dot or forward slash notation path exists and
+// holds a JSON null.
+func (g *Container) IsNullPath(path string) bool {
+	return g.IsNull(PathToSlice(path)...)
+}
+
 // Children returns a slice of all children of an array element. This also works
-// for objects, however, the children returned for an object will be in a random
-// order and you lose the names of the returned objects this way. If the
-// underlying container value isn't an array or map nil is returned.
+// for objects, however, you lose the names of the returned objects this way:
+// the children of an *Object come in its key order, those of a map sorted by
+// key. If the underlying container value isn't an array or object nil is
+// returned.
 func (g *Container) Children() []*Container {
 	if array, ok := g.Data().([]any); ok {
 		children := make([]*Container, len(array))
 		for i := 0; i < len(array); i++ {
-			children[i] = &Container{array[i]}
+			children[i] = g.child(array[i], strconv.Itoa(i))
 		}
 		return children
 	}
-	if mmap, ok := g.Data().(map[string]any); ok {
+	if keys, ok := objectKeys(g.Data()); ok {
 		children := []*Container{}
-		for _, obj := range mmap {
-			children = append(children, &Container{obj})
+		for _, key := range keys {
+			obj, _ := objectGet(g.Data(), key)
+			children = append(children, g.child(obj, key))
 		}
 		return children
 	}
@@ -141,10 +189,11 @@ func (g *Container) Children() []*Container {
 // ChildrenMap returns a map of all the children of an object element. IF the
 // underlying value isn't a object then an empty map is returned.
 func (g *Container) ChildrenMap() map[string]*Container {
-	if mmap, ok := g.Data().(map[string]any); ok {
-		children := make(map[string]*Container, len(mmap))
-		for name, obj := range mmap {
-			children[name] = &Container{obj}
+	if keys, ok := objectKeys(g.Data()); ok {
+		children := make(map[string]*Container, len(keys))
+		for _, name := range keys {
+			obj, _ := objectGet(g.Data(), name)
+			children[name] = g.child(obj, name)
 		}
 		return children
 	}
@@ -153,13 +202,13 @@ func (g *Container) ChildrenMap() map[string]*Container {
 
 // New creates a new Container JSON object.
 func New() *Container {
-	return &Container{map[string]any{}}
+	return &Container{object: map[string]any{}}
 }
 
 // Wrap an already unmarshalled JSON object (or a new map[string]any)
 // into a *Container.
 func Wrap(root any) *Container {
-	return &Container{root}
+	return &Container{object: root}
 }
 
 // Data returns the underlying value of the target element in the wrapped
@@ -171,9 +220,13 @@ func (g *Container) Data() any {
 	return g.object
 }
 
-// String marshals an element to a JSON formatted string.
+// String marshals an element to a JSON formatted string. A string element is
+// returned as is.
 func (g *Container) String() string {
-	return g.Data().(string)
+	if data, ok := g.Data().(string); ok {
+		return data
+	}
+	return Marshal(g.Data())
 }
 
 // PathToSlice returns a slice of path segments parsed out of a dot or forward slash path.
@@ -198,7 +251,7 @@ func PathToSlice(path string) []string {
 			hierarchy[i] = v
 		}
 	} else {
-		hierarchy := strings.Split(path, "/")[1:]
+		hierarchy = strings.Split(path, "/")[1:]
 		for i, v := range hierarchy {
 			v = strings.Replace(v, "~1", "/", -1)
 			v = strings.Replace(v, "~0", "~", -1)
@@ -221,6 +274,51 @@ func PathToSlice(path string) []string {
 //
 // Returns a container of the new value or an error.
 func (g *Container) Set(value any, hierarchy ...string) (*Container, error) {
+	if g != nil && g.root != nil {
+		result, err := g.root.Set(value, g.inDocument(hierarchy)...)
+		g.refresh()
+		return result, err
+	}
+	if g == nil || g.doc == nil {
+		return g.set(value, hierarchy...)
+	}
+
+	// Keep the data and the text of a document in step.
+	saved := deepCopy(g.object)
+	result, err := g.set(value, hierarchy...)
+	if err == nil {
+		err = g.doc.set(g, hierarchy)
+	}
+	if err != nil {
+		g.object = saved
+		return nil, err
+	}
+	if len(hierarchy) == 0 {
+		return g, nil
+	}
+	return g.child(result.object, g.resolve(hierarchy)...), nil
+}
+
+// resolve returns hierarchy, which Set has just built, with each '-' replaced
+// by the index of the element it appended.
+func (g *Container) resolve(hierarchy []string) []string {
+	resolved := append([]string{}, hierarchy...)
+	object := g.object
+	for i, seg := range resolved {
+		if array, ok := object.([]any); ok {
+			if seg == "-" {
+				resolved[i] = strconv.Itoa(len(array) - 1)
+			}
+			index, _ := arrayIndex(resolved[i])
+			object = array[index]
+		} else {
+			object, _ = objectGet(object, seg)
+		}
+	}
+	return resolved
+}
+
+func (g *Container) set(value any, hierarchy ...string) (*Container, error) {
 	if g == nil {
 		return nil, errors.New("failed to resolve path, container is nil")
 	}
@@ -235,13 +333,13 @@ func (g *Container) Set(value any, hierarchy ...string) (*Container, error) {
 
 	for target := 0; target < len(hierarchy); target++ {
 		pathSeg := hierarchy[target]
-		if mmap, ok := object.(map[string]any); ok {
+		if parent := object; isObject(parent) {
 			if target == len(hierarchy)-1 {
 				object = value
-				mmap[pathSeg] = object
-			} else if object = mmap[pathSeg]; object == nil {
-				mmap[pathSeg] = map[string]any{}
-				object = mmap[pathSeg]
+				objectSet(parent, pathSeg, object)
+			} else if object, _ = objectGet(parent, pathSeg); object == nil {
+				object = g.newObject()
+				objectSet(parent, pathSeg, object)
 			}
 		} else if marray, ok := object.([]any); ok {
 			if pathSeg == "-" {
@@ -251,35 +349,32 @@ func (g *Container) Set(value any, hierarchy ...string) (*Container, error) {
 				if target == len(hierarchy)-1 {
 					object = value
 				} else {
-					object = map[string]any{}
+					object = g.newObject()
 				}
 				marray = append(marray, object)
-				if _, err := g.Set(marray, hierarchy[:target]...); err != nil {
+				if _, err := g.set(marray, hierarchy[:target]...); err != nil {
 					return nil, err
 				}
 			} else {
-				index, err := strconv.Atoi(pathSeg)
+				index, err := arrayIndex(pathSeg)
 				if err != nil {
-					return nil, fmt.Errorf("failed to resolve path segment '%v': found array but segment value '%v' could not be parsed into array index: %v", target, pathSeg, err)
-				}
-				if index < 0 {
-					return nil, fmt.Errorf("failed to resolve path segment '%v': found array but index '%v' is invalid", target, pathSeg)
+					return nil, fmt.Errorf("failed to resolve '%v': found array but %v", pointerPrefix(hierarchy, target), err)
 				}
 				if len(marray) <= index {
-					return nil, fmt.Errorf("failed to resolve path segment '%v': found array but index '%v' exceeded target array size of '%v'", target, pathSeg, len(marray))
+					return nil, fmt.Errorf("failed to resolve '%v': found array but index '%v' exceeded target array size of '%v'", pointerPrefix(hierarchy, target), pathSeg, len(marray))
 				}
 				if target == len(hierarchy)-1 {
 					object = value
 					marray[index] = object
 				} else if object = marray[index]; object == nil {
-					return nil, fmt.Errorf("failed to resolve path segment '%v': field '%v' was not found", target, pathSeg)
+					return nil, fmt.Errorf("failed to resolve '%v': field '%v' was not found", pointerPrefix(hierarchy, target), pathSeg)
 				}
 			}
 		} else {
 			return nil, ErrPathCollision
 		}
 	}
-	return &Container{object}, nil
+	return &Container{object: object}, nil
 }
 
 // SetPath sets the value of a field at a path using dot or forward slash notation, any parts
@@ -340,6 +435,27 @@ func (g *Container) ArrayPath(path string) (*Container, error) {
 // exist or is not an object. In order to remove an array element please use
 // ArrayRemove.
 func (g *Container) Delete(hierarchy ...string) error {
+	if g != nil && g.root != nil && len(hierarchy) > 0 {
+		err := g.root.Delete(g.inDocument(hierarchy)...)
+		g.refresh()
+		return err
+	}
+	if g == nil || g.doc == nil {
+		return g.delete(hierarchy)
+	}
+
+	saved := deepCopy(g.object)
+	err := g.delete(hierarchy)
+	if err == nil {
+		err = g.doc.delete(hierarchy)
+	}
+	if err != nil {
+		g.object = saved
+	}
+	return err
+}
+
+func (g *Container) delete(hierarchy []string) error {
 	if g == nil || g.object == nil {
 		return ErrNotObj
 	}
@@ -353,29 +469,25 @@ func (g *Container) Delete(hierarchy ...string) error {
 		object = g.Search(hierarchy[:len(hierarchy)-1]...).Data()
 	}
 
-	if obj, ok := object.(map[string]any); ok {
-		if _, ok = obj[target]; !ok {
+	if isObject(object) {
+		if !objectDelete(object, target) {
 			return ErrNotFound
 		}
-		delete(obj, target)
 		return nil
 	}
 	if array, ok := object.([]any); ok {
 		if len(hierarchy) < 2 {
 			return errors.New("unable to delete array index at root of path")
 		}
-		index, err := strconv.Atoi(target)
+		index, err := arrayIndex(target)
 		if err != nil {
-			return fmt.Errorf("failed to parse array index '%v': %v", target, err)
+			return fmt.Errorf("failed to resolve '%v': %v", Pointer(hierarchy), err)
 		}
 		if index >= len(array) {
 			return ErrOutOfBounds
 		}
-		if index < 0 {
-			return ErrOutOfBounds
-		}
 		array = append(array[:index], array[index+1:]...)
-		g.Set(array, hierarchy[:len(hierarchy)-1]...)
+		g.set(array, hierarchy[:len(hierarchy)-1]...)
 		return nil
 	}
 	return ErrNotObjOrArray
@@ -394,28 +506,20 @@ func (g *Container) DeletePath(path string) error {
 // Which ever value is returned becomes the new value in the destination object
 // at the location of the collision.
 func (g *Container) MergeFn(source *Container, collisionFn func(destination, source any) any) error {
-	var recursiveFnc func(map[string]any, []string) error
-	recursiveFnc = func(mmap map[string]any, path []string) error {
-		for key, value := range mmap {
+	var recursiveFnc func(any, []string) error
+	recursiveFnc = func(object any, path []string) error {
+		keys, _ := objectKeys(object)
+		for _, key := range keys {
+			value, _ := objectGet(object, key)
 			newPath := append(path, key)
 			if g.Exist(newPath...) {
 				existingData := g.Search(newPath...).Data()
-				switch t := value.(type) {
-				case map[string]any:
-					switch existingVal := existingData.(type) {
-					case map[string]any:
-						if err := recursiveFnc(t, newPath); err != nil {
-							return err
-						}
-					default:
-						if _, err := g.Set(collisionFn(existingVal, t), newPath...); err != nil {
-							return err
-						}
-					}
-				default:
-					if _, err := g.Set(collisionFn(existingData, t), newPath...); err != nil {
+				if isObject(value) && isObject(existingData) {
+					if err := recursiveFnc(value, newPath); err != nil {
 						return err
 					}
+				} else if _, err := g.Set(collisionFn(existingData, value), newPath...); err != nil {
+					return err
 				}
 			} else {
 				// path doesn't exist. So set the value
@@ -426,8 +530,8 @@ func (g *Container) MergeFn(source *Container, collisionFn func(destination, sou
 		}
 		return nil
 	}
-	if mmap, ok := source.Data().(map[string]any); ok {
-		return recursiveFnc(mmap, []string{})
+	if isObject(source.Data()) {
+		return recursiveFnc(source.Data(), []string{})
 	}
 	return nil
 }
@@ -439,7 +543,8 @@ func (g *Container) MergeFn(source *Container, collisionFn func(destination, sou
 // resulting array.
 //
 // It is possible to merge structures will different collision behaviours with
-// MergeFn.
+// MergeFn, or to have source values replace the destination ones with
+// MergePatch.
 func (g *Container) Merge(source *Container) error {
 	return g.MergeFn(source, func(dest, source any) any {
 		destArr, destIsArray := dest.([]any)
@@ -526,3 +631,74 @@ func (g *Container) ArrayRemove(index int, hierarchy ...string) error {
 func (g *Container) ArrayRemovePath(index int, path string) error {
 	return g.ArrayRemove(index, PathToSlice(path)...)
 }
+
+// newObject returns an empty object for Set to build paths with: an *Object
+// when the root of g is one, otherwise a map.
+func (g *Container) newObject() any {
+	if _, ok := g.object.(*Object); ok {
+		return NewObject()
+	}
+	return map[string]any{}
+}
+
+// isObject reports whether object is a map[string]any or an *Object.
+func isObject(object any) bool {
+	switch object.(type) {
+	case map[string]any, *Object:
+		return true
+	}
+	return false
+}
+
+// objectKeys returns the keys of an object: in order for an *Object and
+// sorted for a map. ok is false when object is not an object.
+func objectKeys(object any) ([]string, bool) {
+	switch t := object.(type) {
+	case *Object:
+		return t.Keys(), true
+	case map[string]any:
+		keys := make([]string, 0, len(t))
+		for key := range t {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return keys, true
+	}
+	return nil, false
+}
+
+// objectGet returns the value of key in an object and whether it is present.
+func objectGet(object any, key string) (any, bool) {
+	switch t := object.(type) {
+	case *Object:
+		return t.Get(key)
+	case map[string]any:
+		value, ok := t[key]
+		return value, ok
+	}
+	return nil, false
+}
+
+// objectSet sets the value of key in an object.
+func objectSet(object any, key string, value any) {
+	switch t := object.(type) {
+	case *Object:
+		t.Set(key, value)
+	case map[string]any:
+		t[key] = value
+	}
+}
+
+// objectDelete removes key from an object and reports whether it was present.
+func objectDelete(object any, key string) bool {
+	switch t := object.(type) {
+	case *Object:
+		return t.Delete(key)
+	case map[string]any:
+		if _, ok := t[key]; ok {
+			delete(t, key)
+			return true
+		}
+	}
+	return false
+}
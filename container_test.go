@@ -34,7 +34,7 @@ var jsonStr string = `{
 var container *Container = Parse(jsonStr)
 
 func TestPath(t *testing.T) {
-	if container.Path("employees.protected").Data() != "false" {
+	if container.Path("employees.protected").Data() != false {
 		t.Fatalf("TestParse expected Type=%s, Got=%s", "false",
 			container.Path("employees.protected").Data())
 	}
@@ -186,3 +186,71 @@ func TestContactArray(t *testing.T) {
 			fmt.Sprintf("%v", jsonObj.Data()))
 	}
 }
+
+func TestIsNull(t *testing.T) {
+	jsonParsed := Parse(`{"outter":{"value":null,"zero":0,"array":[null]}}`)
+
+	if !jsonParsed.IsNull("outter", "value") || !jsonParsed.IsNullPath("outter.array.0") {
+		t.Fatalf("TestIsNull expected null at outter.value and outter.array.0")
+	}
+	if jsonParsed.IsNull("outter", "zero") || jsonParsed.IsNullPath("outter.missing") {
+		t.Fatalf("TestIsNull expected no null at outter.zero and outter.missing")
+	}
+	if !jsonParsed.ExistPath("outter.value") {
+		t.Fatalf("TestIsNull expected outter.value to exist")
+	}
+	if jsonParsed.Path("outter.value").String() != "null" {
+		t.Fatalf("TestIsNull expected Type=%s, Got=%s", "null", jsonParsed.Path("outter.value").String())
+	}
+}
+
+func TestKeepOrder(t *testing.T) {
+	jsonParsed, err := ParseE(`{"zeta":1,"alpha":{"y":2,"x":3},"mid":[{"b":4,"a":5}]}`, KeepOrder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := jsonParsed.Data().(*Object); !ok {
+		t.Fatalf("TestKeepOrder expected *Object, Got=%T", jsonParsed.Data())
+	}
+
+	expected := `{"zeta":1,"alpha":{"y":2,"x":3},"mid":[{"b":4,"a":5}]}`
+	if jsonParsed.String() != expected {
+		t.Fatalf("TestKeepOrder expected Type=%s, Got=%s", expected, jsonParsed.String())
+	}
+
+	var names []string
+	for _, child := range jsonParsed.Path("alpha").Children() {
+		names = append(names, child.String())
+	}
+	if fmt.Sprint(names) != "[2 3]" {
+		t.Fatalf("TestKeepOrder expected Type=%s, Got=%v", "[2 3]", names)
+	}
+
+	jsonParsed.SetPath(6, "beta.gamma")
+	jsonParsed.SetPath(7, "zeta")
+	jsonParsed.DeletePath("alpha.y")
+	expected = `{"zeta":7,"alpha":{"x":3},"mid":[{"b":4,"a":5}],"beta":{"gamma":6}}`
+	if jsonParsed.String() != expected {
+		t.Fatalf("TestKeepOrder expected Type=%s, Got=%s", expected, jsonParsed.String())
+	}
+	if _, ok := jsonParsed.Path("beta").Data().(*Object); !ok {
+		t.Fatalf("TestKeepOrder expected *Object for a new path, Got=%T", jsonParsed.Path("beta").Data())
+	}
+
+	jsonParsed.Merge(Parse(`{"alpha":{"w":8}}`, KeepOrder()))
+	if keys := jsonParsed.Path("alpha").Data().(*Object).Keys(); fmt.Sprint(keys) != "[x w]" {
+		t.Fatalf("TestKeepOrder expected Type=%s, Got=%v", "[x w]", keys)
+	}
+}
+
+func TestChildrenSorted(t *testing.T) {
+	jsonParsed := Parse(`{"c":3,"a":1,"b":2}`)
+
+	var values []string
+	for _, child := range jsonParsed.Children() {
+		values = append(values, child.String())
+	}
+	if fmt.Sprint(values) != "[1 2 3]" {
+		t.Fatalf("TestChildrenSorted expected Type=%s, Got=%v", "[1 2 3]", values)
+	}
+}
@@ -0,0 +1,367 @@
+package json
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+
+	"github.com/qw20012/go-json/lexer"
+	"github.com/qw20012/go-json/parser"
+	"github.com/qw20012/go-json/token"
+)
+
+// InvalidUnmarshalError describes an invalid argument passed to Decode.
+type InvalidUnmarshalError struct {
+	Type reflect.Type
+}
+
+func (e *InvalidUnmarshalError) Error() string {
+	if e.Type == nil {
+		return "Decode(nil)"
+	}
+	if e.Type.Kind() != reflect.Ptr {
+		return "Decode(non-pointer " + e.Type.String() + ")"
+	}
+	return "Decode(nil " + e.Type.String() + ")"
+}
+
+// Decoder reads values one after the other from an input stream, such as
+// concatenated or newline-delimited JSON. Input is read as it is needed, and
+// only a bounded window of it is kept in memory beside the value being
+// decoded.
+type Decoder struct {
+	r      io.Reader
+	strict bool
+	opts   []DecodeOption
+	parser *parser.Parser
+	err    error // syntax or read error that stopped the decoder
+
+	stack []tokenFrame // objects and arrays opened by Token
+}
+
+// NewDecoder returns a decoder that reads from r. The options apply to every
+// value it decodes.
+func NewDecoder(r io.Reader, opts ...DecodeOption) *Decoder {
+	return &Decoder{r: r, opts: opts}
+}
+
+// UseStrict makes the decoder accept RFC 8259 JSON only. It must be called
+// before the first Decode or More.
+func (dec *Decoder) UseStrict() {
+	dec.strict = true
+}
+
+func (dec *Decoder) lexer() *lexer.Lexer {
+	if dec.parser == nil {
+		if dec.strict {
+			dec.parser = parser.NewParser(lexer.NewStrictReaderLexer(dec.r))
+		} else {
+			dec.parser = parser.NewParser(lexer.NewReaderLexer(dec.r))
+		}
+	}
+	return dec.parser.Lexer
+}
+
+// Decode reads the next value from the input and stores it in the value
+// pointed to by v, as UnmarshalE does. It returns io.EOF when the input has
+// no more values. A *SyntaxError or an error reading the input stops the
+// decoder: every later call returns it again.
+//
+// Decode may be mixed with Token: inside an object or array opened by Token,
+// it reads the value of the key just returned, or the next array element.
+func (dec *Decoder) Decode(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return &InvalidUnmarshalError{Type: reflect.TypeOf(v)}
+	}
+
+	if dec.err != nil {
+		return dec.err
+	}
+	if err := dec.prepareValue(); err != nil {
+		return err
+	}
+
+	l := dec.lexer()
+	ast, err := dec.parser.ParseValue()
+	if err == io.EOF && l.Err() == nil && len(dec.stack) == 0 {
+		return err
+	}
+	if err != nil {
+		return dec.fail(err)
+	}
+	dec.valueDone()
+	return buildInto(rv.Elem(), ast, "", dec.opts)
+}
+
+// fail stops the decoder with err, or with the read error behind it.
+func (dec *Decoder) fail(err error) error {
+	dec.err = err
+	if readErr := dec.lexer().Err(); readErr != nil {
+		dec.err = readErr
+	}
+	return dec.err
+}
+
+// More reports whether there is another value to decode: in the input, or in
+// the object or array that Token last opened. It reads ahead as far as the
+// start of that value.
+func (dec *Decoder) More() bool {
+	l := dec.lexer()
+	if frame := dec.top(); frame != nil && (frame.state == arrayNext || frame.state == objectNext) &&
+		l.PeakToken().Type == token.COMMA {
+		l.NewToken()
+		frame.comma()
+	}
+
+	switch l.PeakToken().Type {
+	case token.EOF, token.RBRACE, token.RBRACKET:
+		return false
+	}
+	return true
+}
+
+// Token is a piece of the input returned by Decoder.Token: a Delim for the
+// braces and brackets of objects and arrays, a Key for an object key, a
+// string, an int64, uint64 or float64 for a number, a bool, or nil for null.
+type Token any
+
+// Delim is one of '{', '}', '[' and ']'.
+type Delim rune
+
+func (d Delim) String() string {
+	return string(d)
+}
+
+// Key is an object key.
+type Key string
+
+type tokenState int
+
+const (
+	objectStart tokenState = iota // after '{', expecting a key or '}'
+	objectColon                   // after a key, expecting ':'
+	objectValue                   // after ':', expecting a value
+	objectNext                    // after a value, expecting ',' or '}'
+	objectComma                   // after ',', expecting a key
+	arrayStart                    // after '[', expecting a value or ']'
+	arrayNext                     // after a value, expecting ',' or ']'
+	arrayComma                    // after ',', expecting a value
+)
+
+// tokenFrame is an object or array opened by Token.
+type tokenFrame struct {
+	state tokenState
+	key   string // key of the current entry of an object
+	index int    // index of the current element of an array
+}
+
+// comma moves past the ',' after an entry or element.
+func (frame *tokenFrame) comma() {
+	if frame.state == arrayNext {
+		frame.state = arrayComma
+	} else {
+		frame.state = objectComma
+	}
+}
+
+// element starts the next element of an array.
+func (frame *tokenFrame) element() {
+	if frame.state == arrayComma {
+		frame.index++
+	}
+	frame.state = arrayNext
+}
+
+func (dec *Decoder) top() *tokenFrame {
+	if len(dec.stack) == 0 {
+		return nil
+	}
+	return &dec.stack[len(dec.stack)-1]
+}
+
+// Token returns the next token of the input without building values for
+// objects and arrays, which lets a program pick a few values out of a large
+// document. Commas and colons are checked but not returned. It returns
+// io.EOF at the end of the input.
+func (dec *Decoder) Token() (Token, error) {
+	if dec.err != nil {
+		return nil, dec.err
+	}
+
+	l := dec.lexer()
+	for {
+		tok := l.NewToken()
+		frame := dec.top()
+		if frame == nil {
+			if tok.Type == token.EOF && l.Err() == nil {
+				return nil, io.EOF
+			}
+			return dec.tokenValue(tok)
+		}
+
+		switch frame.state {
+		case objectStart, objectComma:
+			if tok.Type == token.RBRACE {
+				return dec.closeFrame(frame, tok)
+			}
+			if !parser.IsKey(tok) {
+				return nil, dec.fail(dec.parser.Expected("object key", tok))
+			}
+			frame.key, frame.state = string(tok.Lit), objectColon
+			return Key(tok.Lit), nil
+		case objectColon:
+			if tok.Type != token.COLON {
+				return nil, dec.fail(dec.parser.Expected(fmt.Sprintf("':' after key %q", frame.key), tok))
+			}
+			frame.state = objectValue
+		case objectValue:
+			frame.state = objectNext
+			return dec.tokenValue(tok)
+		case objectNext:
+			switch tok.Type {
+			case token.COMMA:
+				frame.comma()
+			case token.RBRACE:
+				return dec.closeFrame(frame, tok)
+			default:
+				return nil, dec.fail(dec.parser.Expected(fmt.Sprintf("',' or '}' after value of key %q", frame.key), tok))
+			}
+		case arrayStart, arrayComma:
+			if tok.Type == token.RBRACKET {
+				return dec.closeFrame(frame, tok)
+			}
+			frame.element()
+			return dec.tokenValue(tok)
+		case arrayNext:
+			switch tok.Type {
+			case token.COMMA:
+				frame.comma()
+			case token.RBRACKET:
+				return dec.closeFrame(frame, tok)
+			default:
+				return nil, dec.fail(dec.parser.Expected("',' or ']' after array element", tok))
+			}
+		}
+	}
+}
+
+// tokenValue returns the token starting a value, opening a frame for an
+// object or an array.
+func (dec *Decoder) tokenValue(tok token.Token) (Token, error) {
+	switch tok.Type {
+	case token.LBRACE:
+		dec.stack = append(dec.stack, tokenFrame{state: objectStart})
+		return Delim('{'), nil
+	case token.LBRACKET:
+		dec.stack = append(dec.stack, tokenFrame{state: arrayStart})
+		return Delim('['), nil
+	}
+
+	value, err := dec.parser.ParseToken(tok)
+	if err != nil {
+		return nil, dec.fail(err)
+	}
+	return value, nil
+}
+
+// closeFrame closes the innermost object or array. In strict mode a comma
+// before the closing delimiter is an error.
+func (dec *Decoder) closeFrame(frame *tokenFrame, tok token.Token) (Token, error) {
+	if (frame.state == objectComma || frame.state == arrayComma) && dec.lexer().Strict() {
+		return nil, dec.fail(dec.lexer().Errorf(tok.Pos, "trailing comma before '%s'", string(tok.Lit)))
+	}
+
+	dec.stack = dec.stack[:len(dec.stack)-1]
+	if tok.Type == token.RBRACE {
+		return Delim('}'), nil
+	}
+	return Delim(']'), nil
+}
+
+// prepareValue moves past the ':' or ',' in front of the next value inside a
+// frame opened by Token.
+func (dec *Decoder) prepareValue() error {
+	frame := dec.top()
+	if frame == nil {
+		return nil
+	}
+
+	l := dec.lexer()
+	switch frame.state {
+	case objectColon:
+		if tok := l.NewToken(); tok.Type != token.COLON {
+			return dec.fail(dec.parser.Expected(fmt.Sprintf("':' after key %q", frame.key), tok))
+		}
+		frame.state = objectValue
+	case arrayNext:
+		if tok := l.NewToken(); tok.Type != token.COMMA {
+			return dec.fail(dec.parser.Expected("',' or ']' after array element", tok))
+		}
+		frame.comma()
+	case objectStart, objectComma, objectNext:
+		return errors.New("no value to read: the decoder is expecting an object key")
+	}
+	return nil
+}
+
+// valueDone records that the value prepared by prepareValue has been read.
+func (dec *Decoder) valueDone() {
+	if frame := dec.top(); frame != nil {
+		if frame.state == objectValue {
+			frame.state = objectNext
+		} else {
+			frame.element()
+		}
+	}
+}
+
+// SkipValue reads past the next value, checking its syntax but building
+// nothing. Inside an object it skips the value of the key just returned by
+// Token.
+func (dec *Decoder) SkipValue() error {
+	if dec.err != nil {
+		return dec.err
+	}
+	if err := dec.prepareValue(); err != nil {
+		return err
+	}
+
+	depth := len(dec.stack)
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if len(dec.stack) < depth {
+			return fmt.Errorf("no value to skip before %v", tok)
+		}
+		if len(dec.stack) == depth {
+			return nil
+		}
+	}
+}
+
+// Depth returns the number of objects and arrays opened by Token that are
+// still open.
+func (dec *Decoder) Depth() int {
+	return len(dec.stack)
+}
+
+// Path returns the keys and array indexes leading to the last token returned
+// by Token: for a key, the path ends with that key; for a delimiter, it is the
+// path of its object or array.
+func (dec *Decoder) Path() []string {
+	path := []string{}
+	for _, frame := range dec.stack {
+		switch frame.state {
+		case objectColon, objectValue, objectNext, objectComma:
+			path = append(path, frame.key)
+		case arrayNext, arrayComma:
+			path = append(path, strconv.Itoa(frame.index))
+		}
+	}
+	return path
+}
@@ -0,0 +1,235 @@
+package json
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestDecoder(t *testing.T) {
+	input := `{"name": "a", "count": 1}
+{"name": "b", "count": 2} {name: c, count: 3} // relaxed
+[1, 2] "text" 42 null`
+
+	type Item struct {
+		Name  string `json:"name"`
+		Count int    `json:"count"`
+	}
+
+	dec := NewDecoder(iotest.HalfReader(strings.NewReader(input)))
+	var items []Item
+	for i := 0; i < 3; i++ {
+		var item Item
+		if err := dec.Decode(&item); err != nil {
+			t.Fatalf("On item[%d], expected no error, Got=%v", i, err)
+		}
+		items = append(items, item)
+	}
+	want := []Item{{"a", 1}, {"b", 2}, {"c", 3}}
+	if !reflect.DeepEqual(items, want) {
+		t.Fatalf("TestDecoder expected %v, Got=%v", want, items)
+	}
+
+	var rest []any
+	for dec.More() {
+		var value any
+		if err := dec.Decode(&value); err != nil {
+			t.Fatalf("TestDecoder expected no error, Got=%v", err)
+		}
+		rest = append(rest, value)
+	}
+	if want := []any{[]any{int64(1), int64(2)}, "text", int64(42), nil}; !reflect.DeepEqual(rest, want) {
+		t.Fatalf("TestDecoder expected %v, Got=%v", want, rest)
+	}
+
+	var value any
+	if err := dec.Decode(&value); err != io.EOF {
+		t.Fatalf("TestDecoder expected io.EOF, Got=%v", err)
+	}
+	if err := dec.Decode(value); err == nil || err.Error() != "Decode(nil)" {
+		t.Fatalf("TestDecoder expected an *InvalidUnmarshalError, Got=%v", err)
+	}
+}
+
+func TestDecoderErrors(t *testing.T) {
+	dec := NewDecoder(strings.NewReader("{\"a\": 1}\n{\"a\" 2}\n{\"a\": 3}"))
+	dec.UseStrict()
+
+	var value map[string]int
+	if err := dec.Decode(&value); err != nil || value["a"] != 1 {
+		t.Fatalf("TestDecoderErrors expected a=1, Got=%v (%v)", value, err)
+	}
+	for i := 0; i < 2; i++ {
+		err := dec.Decode(&value)
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) || syntaxErr.Pos.Line != 2 || syntaxErr.Pos.Column != 6 {
+			t.Fatalf("On call[%d], expected a *SyntaxError at 2:6, Got=%v", i, err)
+		}
+	}
+
+	dec = NewDecoder(strings.NewReader("a: b"))
+	dec.UseStrict()
+	if err := dec.Decode(&value); err == nil || !strings.Contains(err.Error(), `unquoted string "a"`) {
+		t.Fatalf("TestDecoderErrors expected an unquoted string error, Got=%v", err)
+	}
+
+	dec = NewDecoder(io.MultiReader(strings.NewReader("[1, "), iotest.ErrReader(io.ErrClosedPipe)))
+	var list []int
+	if err := dec.Decode(&list); err != io.ErrClosedPipe {
+		t.Fatalf("TestDecoderErrors expected the read error, Got=%v", err)
+	}
+
+	dec = NewDecoder(strings.NewReader(`{Cuont: 1}`), UnknownFields(RejectUnknown))
+	var item struct{ Count int }
+	if err := dec.Decode(&item); err == nil || err.Error() != "unknown field Cuont (did you mean Count?)" {
+		t.Fatalf("TestDecoderErrors expected an unknown field error, Got=%v", err)
+	}
+}
+
+func TestDecoderDoesNotReadAhead(t *testing.T) {
+	r, w := io.Pipe()
+	decoded := make(chan bool)
+	go func() {
+		w.Write([]byte(`{"a": 1}`))
+		<-decoded
+		w.Write([]byte("\n[2]"))
+		w.Close()
+	}()
+
+	// The writer sends the second value only once the first is decoded, so
+	// Decode must not wait for input past the end of a value.
+	dec := NewDecoder(r)
+	var first map[string]int
+	if err := dec.Decode(&first); err != nil || first["a"] != 1 {
+		t.Fatalf("TestDecoderDoesNotReadAhead expected a=1, Got=%v (%v)", first, err)
+	}
+	close(decoded)
+
+	var second []int
+	if err := dec.Decode(&second); err != nil || len(second) != 1 || second[0] != 2 {
+		t.Fatalf("TestDecoderDoesNotReadAhead expected [2], Got=%v (%v)", second, err)
+	}
+}
+
+func TestDecoderToken(t *testing.T) {
+	input := `{"a": [1, -2.5, "x", true, null, {}], // comment
+	b: {c: {d: 1}}, "e": []} 7`
+
+	type step struct {
+		token Token
+		depth int
+		path  string
+	}
+	want := []step{
+		{Delim('{'), 1, ""},
+		{Key("a"), 1, "a"},
+		{Delim('['), 2, "a"},
+		{int64(1), 2, "a.0"},
+		{-2.5, 2, "a.1"},
+		{"x", 2, "a.2"},
+		{true, 2, "a.3"},
+		{nil, 2, "a.4"},
+		{Delim('{'), 3, "a.5"},
+		{Delim('}'), 2, "a.5"},
+		{Delim(']'), 1, "a"},
+		{Key("b"), 1, "b"},
+		{Delim('{'), 2, "b"},
+		{Key("c"), 2, "b.c"},
+		{Delim('{'), 3, "b.c"},
+		{Key("d"), 3, "b.c.d"},
+		{int64(1), 3, "b.c.d"},
+		{Delim('}'), 2, "b.c"},
+		{Delim('}'), 1, "b"},
+		{Key("e"), 1, "e"},
+		{Delim('['), 2, "e"},
+		{Delim(']'), 1, "e"},
+		{Delim('}'), 0, ""},
+		{int64(7), 0, ""},
+	}
+
+	dec := NewDecoder(strings.NewReader(input))
+	for i, test := range want {
+		tok, err := dec.Token()
+		if err != nil || tok != test.token || dec.Depth() != test.depth || strings.Join(dec.Path(), ".") != test.path {
+			t.Fatalf("On token[%d], expected %v at depth %d and path %q, Got=%v at depth %d and path %q (%v)",
+				i, test.token, test.depth, test.path, tok, dec.Depth(), strings.Join(dec.Path(), "."), err)
+		}
+	}
+	if tok, err := dec.Token(); err != io.EOF {
+		t.Fatalf("TestDecoderToken expected io.EOF, Got=%v (%v)", tok, err)
+	}
+}
+
+func TestDecoderSkipValue(t *testing.T) {
+	input := `[
+		{"id": 1, "payload": {"big": [1, 2, {"x": [3]}]}, "name": "first"},
+		{"id": 2, "payload": "small", "name": "second"},
+	]`
+
+	dec := NewDecoder(strings.NewReader(input))
+	if tok, err := dec.Token(); err != nil || tok != Delim('[') {
+		t.Fatalf("TestDecoderSkipValue expected '[', Got=%v (%v)", tok, err)
+	}
+
+	var names []string
+	for dec.More() {
+		if tok, err := dec.Token(); err != nil || tok != Delim('{') {
+			t.Fatalf("TestDecoderSkipValue expected '{', Got=%v (%v)", tok, err)
+		}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				t.Fatalf("TestDecoderSkipValue expected a key, Got=%v", err)
+			}
+			if key != Key("name") {
+				if err := dec.SkipValue(); err != nil {
+					t.Fatalf("TestDecoderSkipValue expected no error, Got=%v", err)
+				}
+				continue
+			}
+
+			var name string
+			if err := dec.Decode(&name); err != nil {
+				t.Fatalf("TestDecoderSkipValue expected no error, Got=%v", err)
+			}
+			names = append(names, strings.Join(dec.Path(), ".")+"="+name)
+		}
+		if tok, err := dec.Token(); err != nil || tok != Delim('}') {
+			t.Fatalf("TestDecoderSkipValue expected '}', Got=%v (%v)", tok, err)
+		}
+	}
+	if tok, err := dec.Token(); err != nil || tok != Delim(']') {
+		t.Fatalf("TestDecoderSkipValue expected ']', Got=%v (%v)", tok, err)
+	}
+
+	if want := []string{"0.name=first", "1.name=second"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("TestDecoderSkipValue expected %v, Got=%v", want, names)
+	}
+
+	tests := []struct {
+		input  string
+		strict bool
+		err    string
+	}{
+		{`{"a" 1}`, false, `1:6: expected ':' after key "a", got "1"`},
+		{`[1 2]`, false, `1:4: expected ',' or ']' after array element, got "2"`},
+		{`{"a": 1,}`, true, `1:9: trailing comma before '}'`},
+		{`{"a": [1, {]}`, false, `1:12: expected object key, got "]"`},
+	}
+	for i, test := range tests {
+		dec := NewDecoder(strings.NewReader(test.input))
+		if test.strict {
+			dec.UseStrict()
+		}
+		err := dec.SkipValue()
+		if err == nil || !strings.HasPrefix(err.Error(), test.err) {
+			t.Fatalf("On test[%d], expected %s, Got=%v", i, test.err, err)
+		}
+		if _, again := dec.Token(); again != err {
+			t.Fatalf("On test[%d], expected the error to stop the decoder, Got=%v", i, again)
+		}
+	}
+}
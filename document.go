@@ -0,0 +1,380 @@
+package json
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/qw20012/go-json/lexer"
+	"github.com/qw20012/go-json/parser"
+	"github.com/qw20012/go-json/token"
+)
+
+// ParseDocument parses jsonStr into a Container that keeps the source text.
+// Set and Delete on the returned Container edit the text as well as the
+// data, and Source returns it: unchanged except for the edited values, so
+// comments, whitespace, key order and the spelling of untouched literals
+// survive. Containers found inside it by Search, Children, Query and the
+// like edit it the same way. Changing the data of Data directly does not.
+//
+// Of the duplicate key policies, CollectDuplicates is not supported: the
+// collected array has no text of its own to edit.
+func ParseDocument(jsonStr string, opts ...ParseOption) (*Container, error) {
+	p := newParser(jsonStr, opts...)
+	policy := p.Duplicates()
+	if policy == CollectDuplicates {
+		return nil, errors.New("ParseDocument does not support CollectDuplicates")
+	}
+	ast, err := p.Parse()
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := newDocument(jsonStr, policy == KeepFirst)
+	if err != nil {
+		return nil, err
+	}
+	return &Container{object: ast, doc: doc}, nil
+}
+
+// Source returns the text of a Container parsed by ParseDocument, with the
+// edits made since. Other containers are marshalled as by String.
+func (g *Container) Source() string {
+	if g == nil || g.doc == nil {
+		return g.String()
+	}
+	return g.doc.src
+}
+
+// document is the source text of a Container with its concrete syntax tree.
+type document struct {
+	src     string
+	root    *parser.Node
+	newline string
+	first   bool // the first of duplicate keys counts, as for KeepFirst
+}
+
+func newDocument(src string, first bool) (*document, error) {
+	root, err := parser.NewParser(lexer.NewLexer([]byte(src))).ParseDocument()
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &document{src: src, root: root, newline: "\n", first: first}
+	if strings.Contains(src, "\r\n") {
+		doc.newline = "\r\n"
+	}
+	return doc, nil
+}
+
+// edit replaces the text between start and end.
+type edit struct {
+	start, end int
+	text       string
+}
+
+// apply makes edits, given in order and without overlap, and parses the text
+// again.
+func (d *document) apply(edits ...edit) error {
+	var b strings.Builder
+	last := 0
+	for _, e := range edits {
+		b.WriteString(d.src[last:e.start])
+		b.WriteString(e.text)
+		last = e.end
+	}
+	b.WriteString(d.src[last:])
+
+	next, err := newDocument(b.String(), d.first)
+	if err != nil {
+		return err
+	}
+	*d = *next
+	return nil
+}
+
+// find returns the index of the entry of node for a path segment, or -1. Of
+// duplicate keys the one whose value the data holds counts.
+func (d *document) find(node *parser.Node, seg string) int {
+	switch node.Type {
+	case token.LBRACE:
+		found := -1
+		for i := len(node.Entries) - 1; i >= 0; i-- {
+			if node.Entries[i].Key == seg {
+				found = i
+				if !d.first {
+					break
+				}
+			}
+		}
+		return found
+	case token.LBRACKET:
+		if index, err := strconv.Atoi(seg); err == nil && index >= 0 && index < len(node.Entries) {
+			return index
+		}
+	}
+	return -1
+}
+
+// set writes the text for a value that g.set stored at hierarchy: the first
+// part of the path missing from the text is inserted, or the value replaced
+// when the whole path exists.
+func (d *document) set(g *Container, hierarchy []string) error {
+	node := d.root
+	for k, seg := range hierarchy {
+		if i := d.find(node, seg); i >= 0 {
+			node = node.Entries[i].Value
+			continue
+		}
+
+		switch node.Type {
+		case token.LBRACE:
+			value, err := MarshalE(g.Search(hierarchy[:k+1]...).Data())
+			if err != nil {
+				return err
+			}
+			return d.insert(node, d.key(node, seg)+value)
+		case token.LBRACKET:
+			// Only '-' appends; other indexes exist in the data but not in
+			// the text.
+			if seg != "-" {
+				return fmt.Errorf("failed to resolve '%v' in the source: %w", pointerPrefix(hierarchy, k), ErrOutOfBounds)
+			}
+			array, _ := g.Search(hierarchy[:k]...).Data().([]any)
+			value, err := MarshalE(array[len(array)-1])
+			if err != nil {
+				return err
+			}
+			return d.insert(node, value)
+		}
+
+		// A scalar, such as null, replaced by the objects built through it.
+		return d.replace(node, g.Search(hierarchy[:k]...).Data())
+	}
+	return d.replace(node, g.Search(hierarchy...).Data())
+}
+
+// replace writes value over node.
+func (d *document) replace(node *parser.Node, value any) error {
+	text, err := MarshalE(value)
+	if err != nil {
+		return err
+	}
+
+	start, end := node.Start, node.End
+	if node.Implied && len(node.Entries) > 0 {
+		// Keep the comments around a braceless object.
+		last := node.Entries[len(node.Entries)-1]
+		start, end = node.Entries[0].Start(), last.Value.End
+		if last.Comma >= 0 {
+			end = last.Comma + 1
+		}
+	}
+	return d.apply(edit{start, end, text})
+}
+
+// key returns the text of a new key of node with its colon, quoted unless
+// the keys around it are bare.
+func (d *document) key(node *parser.Node, name string) string {
+	if !isBare(name) {
+		return quote(name, false) + ": "
+	}
+	if len(node.Entries) == 0 {
+		if node.Implied {
+			return name + ": "
+		}
+		return quote(name, false) + ": "
+	}
+
+	e := node.Entries[len(node.Entries)-1]
+	if d.src[e.KeyStart] == '"' {
+		name = quote(name, false)
+	}
+	sep := d.src[e.KeyEnd:e.Value.Start]
+	if len(sep) > 4 || strings.ContainsAny(sep, "\r\n/") {
+		sep = ": "
+	}
+	return name + sep
+}
+
+// insert adds an entry after the last one of node, on a line of its own with
+// the same indent when the last entry has its own line.
+func (d *document) insert(node *parser.Node, text string) error {
+	if len(node.Entries) == 0 {
+		if node.Implied {
+			prefix := ""
+			if node.End > 0 && d.src[node.End-1] != '\n' {
+				prefix = d.newline
+			}
+			return d.apply(edit{node.End, node.End, prefix + text + d.newline})
+		}
+		return d.apply(edit{node.Start + 1, node.Start + 1, text})
+	}
+
+	last := node.Entries[len(node.Entries)-1]
+	lineStart := d.lineStart(last.Start())
+	if lineStart < 0 {
+		if last.Comma >= 0 {
+			return d.apply(edit{last.Comma + 1, last.Comma + 1, " " + text + ","})
+		}
+		return d.apply(edit{last.Value.End, last.Value.End, ", " + text})
+	}
+
+	indent := d.src[lineStart:last.Start()]
+	after := last.Value.End
+	if last.Comma >= 0 {
+		after = last.Comma + 1
+	}
+	lineEnd := d.lineEnd(after)
+	if lineEnd < 0 {
+		lineEnd = after
+	}
+
+	if last.Comma >= 0 {
+		return d.apply(edit{lineEnd, lineEnd, d.newline + indent + text + ","})
+	}
+	return d.apply(
+		edit{last.Value.End, last.Value.End, ","},
+		edit{lineEnd, lineEnd, d.newline + indent + text},
+	)
+}
+
+// delete removes the entry at hierarchy, and the other entries of a
+// duplicate key, which would come back when the text is parsed again.
+func (d *document) delete(hierarchy []string) error {
+	node, i, err := d.lookup(hierarchy)
+	if err != nil {
+		return err
+	}
+	for {
+		if err := d.deleteEntry(node, i); err != nil {
+			return err
+		}
+		if node.Type != token.LBRACE {
+			return nil
+		}
+		if node, i, err = d.lookup(hierarchy); err != nil {
+			return nil
+		}
+	}
+}
+
+// position returns the line and column where the value at hierarchy, or its
+// key, starts, or the zero Pos when the text has no such value.
+func (d *document) position(hierarchy []string, key bool) token.Pos {
+	offset := d.root.Start
+	if len(hierarchy) > 0 {
+		node, i, err := d.lookup(hierarchy)
+		if err != nil {
+			return token.Pos{}
+		}
+		offset = node.Entries[i].Value.Start
+		if key && node.Type == token.LBRACE {
+			offset = node.Entries[i].KeyStart
+		}
+	}
+
+	// Columns count characters, an invalid byte as one, as the lexer does.
+	pos := token.Pos{Offset: offset, Line: 1, Column: 1}
+	for _, char := range d.src[:offset] {
+		if char == '\n' {
+			pos.Line++
+			pos.Column = 1
+		} else {
+			pos.Column++
+		}
+	}
+	return pos
+}
+
+// lookup returns the node holding the entry at hierarchy, with its index.
+func (d *document) lookup(hierarchy []string) (*parser.Node, int, error) {
+	node := d.root
+	i := -1
+	for k, seg := range hierarchy {
+		if k > 0 {
+			node = node.Entries[i].Value
+		}
+		if i = d.find(node, seg); i < 0 {
+			return nil, -1, fmt.Errorf("failed to resolve '%v' in the source: %w", pointerPrefix(hierarchy, k), ErrNotFound)
+		}
+	}
+	return node, i, nil
+}
+
+// deleteEntry removes entry i of node with its comma, and its lines when it
+// has lines of its own.
+func (d *document) deleteEntry(node *parser.Node, i int) error {
+	e := node.Entries[i]
+	start, end := e.Start(), e.Value.End
+	if e.Comma >= 0 {
+		end = e.Comma + 1
+	}
+
+	lineStart, lineEnd := d.lineStart(start), d.lineEnd(end)
+	if lineStart >= 0 && lineEnd >= 0 {
+		if strings.HasPrefix(d.src[lineEnd:], d.newline) {
+			lineEnd += len(d.newline)
+		}
+		if i > 0 && i == len(node.Entries)-1 && e.Comma < 0 {
+			// The comma of the entry before would be left trailing.
+			if comma := node.Entries[i-1].Comma; comma >= 0 {
+				return d.apply(edit{comma, comma + 1, ""}, edit{lineStart, lineEnd, ""})
+			}
+		}
+		return d.apply(edit{lineStart, lineEnd, ""})
+	}
+
+	switch {
+	case i < len(node.Entries)-1:
+		return d.apply(edit{start, node.Entries[i+1].Start(), ""})
+	case i > 0:
+		return d.apply(edit{node.Entries[i-1].Value.End, end, ""})
+	}
+	return d.apply(edit{start, end, ""})
+}
+
+// lineStart returns the offset of the line of pos when only blanks come
+// before pos on it, or -1.
+func (d *document) lineStart(pos int) int {
+	for pos > 0 && (d.src[pos-1] == ' ' || d.src[pos-1] == '\t') {
+		pos--
+	}
+	if pos == 0 || d.src[pos-1] == '\n' {
+		return pos
+	}
+	return -1
+}
+
+// lineEnd returns the offset of the line break after pos, or the end of the
+// text, when only blanks and comments follow pos on its line, or -1.
+func (d *document) lineEnd(pos int) int {
+	for pos < len(d.src) {
+		switch rest := d.src[pos:]; {
+		case rest[0] == ' ' || rest[0] == '\t':
+			pos++
+		case strings.HasPrefix(rest, "//"):
+			if i := strings.IndexByte(rest, '\n'); i >= 0 {
+				pos += i
+			} else {
+				pos = len(d.src)
+			}
+			if pos > 0 && d.src[pos-1] == '\r' {
+				pos--
+			}
+			return pos
+		case strings.HasPrefix(rest, "/*"):
+			i := strings.Index(rest, "*/")
+			if i < 0 || strings.Contains(rest[:i], "\n") {
+				return -1
+			}
+			pos += i + 2
+		case rest[0] == '\n' || strings.HasPrefix(rest, "\r\n"):
+			return pos
+		default:
+			return -1
+		}
+	}
+	return pos
+}
@@ -0,0 +1,311 @@
+package json
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDocumentRoundTrip(t *testing.T) {
+	for _, src := range []string{
+		"",
+		"// only a comment\n",
+		"{\n  // server\n  \"host\": 'example.com', /* inline */ \"port\": 0x1F90,\n}\n",
+		"name: demo,\r\nlist: [1, 2.50, 3e2,]\r\n",
+	} {
+		g, err := ParseDocument(src)
+		if err != nil {
+			t.Fatalf("ParseDocument(%q): %v", src, err)
+		}
+		if got := g.Source(); got != src {
+			t.Errorf("Source() = %q, want %q", got, src)
+		}
+	}
+}
+
+func TestDocumentSet(t *testing.T) {
+	src := `// Service settings.
+{
+  "name": "api",   // shown in logs
+  "port": 8080,
+  "tags": ["a", "b"],
+  "limits": {rate: 10}
+}
+`
+	tests := []struct {
+		value     any
+		hierarchy []string
+		want      string
+	}{
+		{9090, []string{"port"}, `// Service settings.
+{
+  "name": "api",   // shown in logs
+  "port": 9090,
+  "tags": ["a", "b"],
+  "limits": {rate: 10}
+}
+`},
+		{true, []string{"debug"}, `// Service settings.
+{
+  "name": "api",   // shown in logs
+  "port": 8080,
+  "tags": ["a", "b"],
+  "limits": {rate: 10},
+  "debug": true
+}
+`},
+		{"c", []string{"tags", "-"}, `// Service settings.
+{
+  "name": "api",   // shown in logs
+  "port": 8080,
+  "tags": ["a", "b", "c"],
+  "limits": {rate: 10}
+}
+`},
+		{5, []string{"limits", "burst"}, `// Service settings.
+{
+  "name": "api",   // shown in logs
+  "port": 8080,
+  "tags": ["a", "b"],
+  "limits": {rate: 10, burst: 5}
+}
+`},
+		{1, []string{"a", "b"}, `// Service settings.
+{
+  "name": "api",   // shown in logs
+  "port": 8080,
+  "tags": ["a", "b"],
+  "limits": {rate: 10},
+  "a": {"b":1}
+}
+`},
+	}
+
+	for _, test := range tests {
+		g, err := ParseDocument(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := g.Set(test.value, test.hierarchy...); err != nil {
+			t.Fatalf("Set(%v, %v): %v", test.value, test.hierarchy, err)
+		}
+		if got := g.Source(); got != test.want {
+			t.Errorf("Set(%v, %v):\n%s\nwant:\n%s", test.value, test.hierarchy, got, test.want)
+		}
+		if got, want := Parse(g.Source()).Data(), Parse(g.String()).Data(); !reflect.DeepEqual(got, want) {
+			t.Errorf("Set(%v, %v): source has %v, data has %v", test.value, test.hierarchy, got, want)
+		}
+	}
+}
+
+func TestDocumentSetBraceless(t *testing.T) {
+	g, err := ParseDocument("// settings\nname: demo // the name\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Set("prod", "env"); err != nil {
+		t.Fatal(err)
+	}
+	want := "// settings\nname: demo, // the name\nenv: \"prod\"\n"
+	if got := g.Source(); got != want {
+		t.Errorf("Source() = %q, want %q", got, want)
+	}
+
+	g, _ = ParseDocument("")
+	g.Set(1, "a")
+	if got := g.Source(); got != "a: 1\n" {
+		t.Errorf("Source() = %q, want %q", got, "a: 1\n")
+	}
+}
+
+func TestDocumentInvalidUTF8(t *testing.T) {
+	// The edits are made at byte offsets, past a byte that is not UTF-8.
+	g, err := ParseDocument("{a: \"\xff\", b: 1, c: 2}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Set(2, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Delete("c"); err != nil {
+		t.Fatal(err)
+	}
+	if want := "{a: \"\xff\", b: 2}"; g.Source() != want {
+		t.Errorf("Source() = %q, want %q", g.Source(), want)
+	}
+}
+
+func TestDocumentDelete(t *testing.T) {
+	src := "{\n  // the name\n  \"name\": \"api\",\n  \"port\": 8080, // default\n  \"tags\": [\"a\", \"b\", \"c\"]\n}\n"
+	tests := []struct {
+		hierarchy []string
+		want      string
+	}{
+		{[]string{"port"}, "{\n  // the name\n  \"name\": \"api\",\n  \"tags\": [\"a\", \"b\", \"c\"]\n}\n"},
+		{[]string{"tags"}, "{\n  // the name\n  \"name\": \"api\",\n  \"port\": 8080 // default\n}\n"},
+		{[]string{"tags", "0"}, "{\n  // the name\n  \"name\": \"api\",\n  \"port\": 8080, // default\n  \"tags\": [\"b\", \"c\"]\n}\n"},
+		{[]string{"tags", "2"}, "{\n  // the name\n  \"name\": \"api\",\n  \"port\": 8080, // default\n  \"tags\": [\"a\", \"b\"]\n}\n"},
+	}
+
+	for _, test := range tests {
+		g, err := ParseDocument(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := g.Delete(test.hierarchy...); err != nil {
+			t.Fatalf("Delete(%v): %v", test.hierarchy, err)
+		}
+		if got := g.Source(); got != test.want {
+			t.Errorf("Delete(%v) = %q, want %q", test.hierarchy, got, test.want)
+		}
+	}
+
+	g, _ := ParseDocument(src)
+	if err := g.Delete("missing"); err != ErrNotFound {
+		t.Errorf("Delete(missing) = %v, want ErrNotFound", err)
+	}
+	if g.Source() != src {
+		t.Errorf("failed Delete changed the source: %q", g.Source())
+	}
+}
+
+func TestDocumentOutOfStep(t *testing.T) {
+	// Changing the data of a document directly leaves its text behind.
+	doc, err := ParseDocument("{a: {}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.Data().(map[string]any)["a"].(map[string]any)["b"] = map[string]any{"c": 1}
+	if err := doc.Delete("a", "b", "c"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete(a, b, c) = %v, want ErrNotFound", err)
+	}
+	if doc.Search("a", "b", "c").Data() != 1 || doc.Source() != "{a: {}}" {
+		t.Errorf("failed Delete changed the document: %v, %q", doc.Data(), doc.Source())
+	}
+
+	doc, err = ParseDocument("{a: {b: [1]}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.Data().(map[string]any)["a"].(map[string]any)["b"] = []any{1, 2}
+	if _, err := doc.Set(5, "a", "b", "1"); !errors.Is(err, ErrOutOfBounds) {
+		t.Errorf("Set(5, a, b, 1) = %v, want ErrOutOfBounds", err)
+	}
+	if doc.Search("a", "b", "1").Data() != 2 || doc.Source() != "{a: {b: [1]}}" {
+		t.Errorf("failed Set changed the document: %v, %q", doc.Data(), doc.Source())
+	}
+}
+
+func TestDocumentDuplicateKeys(t *testing.T) {
+	src := "{a: 1, b: {x: 1}, a: 2, b: {y: 2}}"
+	tests := []struct {
+		name string
+		opt  ParseOption
+		want string // after Set(9, "a") and Set(5, "b", "x")
+	}{
+		{"KeepLast", DuplicateKeys(KeepLast), "{a: 1, b: {x: 1}, a: 9, b: {y: 2, x: 5}}"},
+		{"KeepFirst", DuplicateKeys(KeepFirst), "{a: 9, b: {x: 5}, a: 2, b: {y: 2}}"},
+		{"Warn", WarnDuplicateKeys(func(DuplicateKey) {}), "{a: 1, b: {x: 1}, a: 9, b: {y: 2, x: 5}}"},
+	}
+
+	for _, test := range tests {
+		g, err := ParseDocument(src, test.opt)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if _, err := g.Set(9, "a"); err != nil {
+			t.Fatalf("%s: Set(9, a): %v", test.name, err)
+		}
+		if _, err := g.Set(5, "b", "x"); err != nil {
+			t.Fatalf("%s: Set(5, b, x): %v", test.name, err)
+		}
+		if g.Source() != test.want {
+			t.Errorf("%s: Source() = %q, want %q", test.name, g.Source(), test.want)
+		}
+
+		// Every entry of a deleted key goes, or parsing the text again would
+		// bring one back.
+		if err := g.Delete("a"); err != nil {
+			t.Fatalf("%s: Delete(a): %v", test.name, err)
+		}
+		got, err := ParseE(g.Source(), test.opt)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(got.Data(), Parse(Marshal(g.Data())).Data()) {
+			t.Errorf("%s: source %q has %v, data has %v", test.name, g.Source(), got.Data(), g.Data())
+		}
+	}
+
+	if _, err := ParseDocument(src, DuplicateKeys(RejectDuplicates)); err == nil {
+		t.Errorf("RejectDuplicates: ParseDocument accepted duplicate keys")
+	}
+	g, err := ParseDocument("{a: 1, b: 2}", DuplicateKeys(RejectDuplicates))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Set(3, "c"); err != nil || g.Source() != "{a: 1, b: 2, c: 3}" {
+		t.Errorf("RejectDuplicates: Set(3, c) = %v, source %q", err, g.Source())
+	}
+
+	if _, err := ParseDocument(src, DuplicateKeys(CollectDuplicates)); err == nil {
+		t.Errorf("CollectDuplicates: ParseDocument succeeded, want an error")
+	}
+}
+
+func TestDocumentChildren(t *testing.T) {
+	doc, err := ParseDocument("{\n  // settings\n  a: {b: 1, list: [1, 2]},\n  c: [{d: 1}]\n}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Containers found inside a document edit its text as well.
+	a := doc.Search("a")
+	if _, err := a.Set(2, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.ArrayAppend(3, "list"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := doc.Path("c.0").Set(true, "e"); err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.Children()[1].Children()[0].Delete("d"); err != nil {
+		t.Fatal(err)
+	}
+	appended, err := doc.Search("a", "list").Set(4, "-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := appended.Set(5); err != nil {
+		t.Fatal(err)
+	}
+	results, err := doc.Query("$.a.list[0]")
+	if err != nil || len(results) != 1 {
+		t.Fatalf("Query() = %v, %v", results, err)
+	}
+	if _, err := results[0].Value.Set(0); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "{\n  // settings\n  a: {b: 2, list: [0,2,3, 5]},\n  c: [{e: true}]\n}\n"
+	if doc.Source() != want {
+		t.Errorf("Source() = %q, want %q", doc.Source(), want)
+	}
+	if got, want := Parse(doc.Source()).Data(), Parse(doc.String()).Data(); !reflect.DeepEqual(got, want) {
+		t.Errorf("source has %v, data has %v", got, want)
+	}
+	if a.Search("b").Data() != 2 || len(a.Search("list").Data().([]any)) != 4 {
+		t.Errorf("a = %v, want the edited value", a.Data())
+	}
+
+	// A failed patch through a child restores the whole document.
+	before := doc.Source()
+	patch := Patch{{Op: "replace", Path: "/b", Value: 9}, {Op: "remove", Path: "/missing"}}
+	if err := a.ApplyPatch(patch); err == nil {
+		t.Fatalf("ApplyPatch() succeeded, want an error")
+	}
+	if doc.Source() != before || a.Search("b").Data() != 2 {
+		t.Errorf("failed ApplyPatch changed the document: %q, %v", doc.Source(), a.Data())
+	}
+}
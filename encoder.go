@@ -0,0 +1,57 @@
+package json
+
+import (
+	"io"
+	"reflect"
+)
+
+// Encoder writes values to an output stream, one per line. Output goes to
+// the writer in chunks as it is produced, so a large value is never held in
+// memory as a whole; if encoding fails, part of the value may already have
+// been written.
+type Encoder struct {
+	w      io.Writer
+	config encodeState
+}
+
+// NewEncoder returns an encoder that writes to w. It writes RFC 8259 JSON
+// unless the options say otherwise.
+func NewEncoder(w io.Writer, opts ...EncodeOption) *Encoder {
+	enc := &Encoder{w: w, config: encodeState{escapeHTML: true}}
+	for _, opt := range opts {
+		opt(&enc.config)
+	}
+	return enc
+}
+
+// Encode writes v followed by a newline.
+func (enc *Encoder) Encode(v any) error {
+	e := newEncodeState()
+	defer e.release()
+	e.human, e.escapeHTML = enc.config.human, enc.config.escapeHTML
+	e.indented, e.prefix, e.indent = enc.config.indented, enc.config.prefix, enc.config.indent
+	e.w = enc.w
+
+	if err := e.marshal(reflect.ValueOf(v)); err != nil {
+		return err
+	}
+	e.buf = append(e.buf, '\n')
+	return e.flush(0)
+}
+
+// SetIndent makes the encoder indent every following value as the Indent
+// option does. Empty prefix and indent turn indentation off.
+func (enc *Encoder) SetIndent(prefix, indent string) {
+	Indent(prefix, indent)(&enc.config)
+}
+
+// SetEscapeHTML sets whether '<', '>' and '&' in strings are escaped.
+func (enc *Encoder) SetEscapeHTML(on bool) {
+	enc.config.escapeHTML = on
+}
+
+// SetHuman chooses between RFC 8259 JSON and the relaxed output of the Human
+// option.
+func (enc *Encoder) SetHuman(on bool) {
+	enc.config.human = on
+}
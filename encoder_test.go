@@ -0,0 +1,112 @@
+package json
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type encoderBook struct {
+	Title  string            `json:"title"`
+	Tags   []string          `json:"tags"`
+	Empty  []int             `json:"empty"`
+	Meta   map[string]string `json:"meta"`
+	Nested struct {
+		Pages int    `json:"pages"`
+		HTML  string `json:"html"`
+	} `json:"nested"`
+}
+
+func TestEncoder(t *testing.T) {
+	book := encoderBook{Title: "a <b>", Tags: []string{"x", "y"}, Empty: []int{}, Meta: map[string]string{"k": "v"}}
+	book.Nested.Pages = 10
+	book.Nested.HTML = "&"
+
+	tests := []struct {
+		setup func(*Encoder, *json.Encoder)
+	}{
+		{func(*Encoder, *json.Encoder) {}},
+		{func(enc *Encoder, std *json.Encoder) { enc.SetIndent(">", "\t"); std.SetIndent(">", "\t") }},
+		{func(enc *Encoder, std *json.Encoder) { enc.SetIndent("", "  "); std.SetIndent("", "  ") }},
+		{func(enc *Encoder, std *json.Encoder) { enc.SetEscapeHTML(false); std.SetEscapeHTML(false) }},
+	}
+	for i, test := range tests {
+		var got, want bytes.Buffer
+		enc, std := NewEncoder(&got), json.NewEncoder(&want)
+		test.setup(enc, std)
+
+		for _, value := range []any{book, []any{}, map[string]int{}, "text", nil} {
+			if err := enc.Encode(value); err != nil {
+				t.Fatalf("On test[%d], expected no error, Got=%v", i, err)
+			}
+			std.Encode(value)
+		}
+		if got.String() != want.String() {
+			t.Fatalf("On test[%d], expected:\n%s\nGot:\n%s", i, want.String(), got.String())
+		}
+	}
+
+	if got, want := Marshal(book, Indent("", "    ")), mustMarshalIndent(book, "", "    "); got != want {
+		t.Fatalf("TestEncoder expected:\n%s\nGot:\n%s", want, got)
+	}
+
+	var got bytes.Buffer
+	enc := NewEncoder(&got)
+	enc.SetHuman(true)
+	enc.SetIndent("", " ")
+	enc.Encode(map[string][]string{"names": {"a b", "c"}})
+	if want := "{\n names: [\n  \"a b\",\n  c\n ]\n}\n"; got.String() != want {
+		t.Fatalf("TestEncoder expected %q, Got=%q", want, got.String())
+	}
+}
+
+func mustMarshalIndent(v any, prefix, indent string) string {
+	out, err := json.MarshalIndent(v, prefix, indent)
+	if err != nil {
+		panic(err)
+	}
+	return string(out)
+}
+
+type countingWriter struct {
+	bytes.Buffer
+	writes int
+	fail   bool
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if w.fail {
+		return 0, errors.New("disk full")
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestEncoderStreams(t *testing.T) {
+	items := make([]string, 20000)
+	for i := range items {
+		items[i] = strings.Repeat("x", i%20)
+	}
+
+	w := &countingWriter{}
+	if err := NewEncoder(w).Encode(items); err != nil {
+		t.Fatalf("TestEncoderStreams expected no error, Got=%v", err)
+	}
+	if w.writes < 2 || w.String() != Marshal(items)+"\n" {
+		t.Fatalf("TestEncoderStreams expected the output in several writes, Got=%d writes", w.writes)
+	}
+
+	w = &countingWriter{fail: true}
+	if err := NewEncoder(w).Encode(items); err == nil || err.Error() != "disk full" || w.writes != 1 {
+		t.Fatalf("TestEncoderStreams expected the write error, Got=%v after %d writes", err, w.writes)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		NewEncoder(&bytes.Buffer{}).Encode([]int{1, 2, 3})
+	})
+	if allocs > 10 {
+		t.Fatalf("TestEncoderStreams expected pooled buffers, Got=%v allocations", allocs)
+	}
+}
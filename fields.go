@@ -0,0 +1,280 @@
+package json
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// field is a struct field as seen by Marshal and Unmarshal, after applying
+// its json tag.
+type field struct {
+	name      string
+	index     []int // index sequence for reflect.Value.FieldByIndex
+	tagged    bool  // name comes from the tag
+	omitEmpty bool  // ",omitempty": skipped by Marshal when empty
+	quoted    bool  // ",string": a scalar written as a JSON string
+	required  bool  // ",required": Unmarshal fails when the key is missing
+}
+
+// structFields holds the fields of a struct type in declaration order, with
+// an index by name for Unmarshal.
+type structFields struct {
+	list    []field
+	byName  map[string]int
+	unknown []int // index of the map field tagged ",unknown", if any
+}
+
+// lookup finds the field for an object key, trying an exact match before a
+// case-insensitive one.
+func (fields *structFields) lookup(key string) *field {
+	if i, ok := fields.byName[key]; ok {
+		return &fields.list[i]
+	}
+	for i := range fields.list {
+		if strings.EqualFold(fields.list[i].name, key) {
+			return &fields.list[i]
+		}
+	}
+	return nil
+}
+
+var fieldCache sync.Map // map[reflect.Type]*structFields
+
+// cachedFields returns the fields of struct type ty.
+func cachedFields(ty reflect.Type) *structFields {
+	if fields, ok := fieldCache.Load(ty); ok {
+		return fields.(*structFields)
+	}
+	fields, _ := fieldCache.LoadOrStore(ty, typeFields(ty))
+	return fields.(*structFields)
+}
+
+// parseTag splits a json struct tag into its name and options.
+func parseTag(tag string) (string, map[string]bool) {
+	name, rest, _ := strings.Cut(tag, ",")
+	options := map[string]bool{}
+	for _, option := range strings.Split(rest, ",") {
+		if option != "" {
+			options[option] = true
+		}
+	}
+	return name, options
+}
+
+// typeFields collects the fields of ty following the rules of encoding/json:
+// a field tagged "-" is ignored, and the fields of an embedded struct without
+// a tag name, or of any struct field tagged ",inline", are promoted into ty.
+// A map field tagged ",unknown" holds the keys that match no other field.
+// When promoted names collide, the shallowest field wins, then the tagged
+// one; a collision that is still undecided hides all fields of that name.
+func typeFields(ty reflect.Type) *structFields {
+	type candidate struct {
+		typ   reflect.Type
+		index []int
+	}
+
+	var found []field
+	var unknown []int
+	visited := map[reflect.Type]bool{}
+	current := []candidate{}
+	next := []candidate{{typ: ty}}
+
+	for len(next) > 0 {
+		current, next = next, current[:0]
+		for _, c := range current {
+			if visited[c.typ] {
+				continue
+			}
+			visited[c.typ] = true
+
+			for i := 0; i < c.typ.NumField(); i++ {
+				sf := c.typ.Field(i)
+				tag := sf.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+
+				name, options := parseTag(tag)
+				index := append(append([]int{}, c.index...), i)
+
+				fieldType := sf.Type
+				if fieldType.Kind() == reflect.Ptr {
+					fieldType = fieldType.Elem()
+				}
+
+				inline := options["inline"] || (sf.Anonymous && name == "")
+				if inline && fieldType.Kind() == reflect.Struct && fieldType != timeType {
+					if !sf.IsExported() && sf.Type.Kind() == reflect.Ptr {
+						// An unexported pointer cannot be allocated by Unmarshal.
+						continue
+					}
+					next = append(next, candidate{typ: fieldType, index: index})
+					continue
+				}
+
+				if !sf.IsExported() {
+					continue
+				}
+
+				if options["unknown"] {
+					if unknown == nil && sf.Type.Kind() == reflect.Map && sf.Type.Key().Kind() == reflect.String {
+						unknown = index
+					}
+					continue
+				}
+
+				quoted := false
+				if options["string"] {
+					switch fieldType.Kind() {
+					case reflect.Bool, reflect.String,
+						reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+						reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+						reflect.Float32, reflect.Float64:
+						quoted = true
+					}
+				}
+
+				found = append(found, field{
+					name:      firstNonEmpty(name, sf.Name),
+					index:     index,
+					tagged:    name != "",
+					omitEmpty: options["omitempty"],
+					quoted:    quoted,
+					required:  options["required"],
+				})
+			}
+		}
+	}
+
+	// Resolve collisions: sort by name, then depth, then tagged first.
+	sort.SliceStable(found, func(i, j int) bool {
+		if found[i].name != found[j].name {
+			return found[i].name < found[j].name
+		}
+		if len(found[i].index) != len(found[j].index) {
+			return len(found[i].index) < len(found[j].index)
+		}
+		return found[i].tagged && !found[j].tagged
+	})
+
+	fields := &structFields{byName: map[string]int{}, unknown: unknown}
+	for i := 0; i < len(found); {
+		j := i + 1
+		for j < len(found) && found[j].name == found[i].name {
+			j++
+		}
+
+		dominant := found[i]
+		if j-i > 1 && len(found[i+1].index) == len(dominant.index) && found[i+1].tagged == dominant.tagged {
+			i = j
+			continue
+		}
+		fields.list = append(fields.list, dominant)
+		i = j
+	}
+
+	// Back to declaration order.
+	sort.Slice(fields.list, func(i, j int) bool {
+		a, b := fields.list[i].index, fields.list[j].index
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+
+	for i, f := range fields.list {
+		fields.byName[f.name] = i
+	}
+	return fields
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// fieldByIndex returns the field of struct v at index. Nil embedded pointers
+// on the way are allocated when alloc is set; otherwise ok is false.
+func fieldByIndex(v reflect.Value, index []int, alloc bool) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !alloc {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// isEmptyValue reports whether v is empty for ",omitempty".
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// closestName returns the field name nearest to key by edit distance, or an
+// empty string when none is close enough to be a likely typo.
+func (fields *structFields) closestName(key string) string {
+	best, bestDistance := "", len(key)/3+2
+	for _, f := range fields.list {
+		if d := editDistance(strings.ToLower(key), strings.ToLower(f.name)); d < bestDistance {
+			best, bestDistance = f.name, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	row := make([]int, len(t)+1)
+	for j := range row {
+		row[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		prev := row[0]
+		row[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			current := row[j]
+			row[j] = row[j] + 1
+			if row[j-1]+1 < row[j] {
+				row[j] = row[j-1] + 1
+			}
+			if prev+cost < row[j] {
+				row[j] = prev + cost
+			}
+			prev = current
+		}
+	}
+	return row[len(t)]
+}
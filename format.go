@@ -0,0 +1,247 @@
+package json
+
+import (
+	"strings"
+
+	"github.com/qw20012/go-json/lexer"
+	"github.com/qw20012/go-json/token"
+)
+
+// Format rewrites jsonStr, in the relaxed syntax, indented canonically: one
+// entry or element per line, one indent per level, ": " after keys and no
+// trailing commas. Comments stay with the entries they belong to, a blank
+// line between entries is kept, and keys, strings and numbers are written as
+// they appear in the source. Outer braces omitted in the source stay omitted.
+func Format(jsonStr string, indent string) (string, error) {
+	f, err := newFormatter(jsonStr)
+	if err != nil {
+		return "", err
+	}
+	f.indent = indent
+	f.format()
+	return string(f.buf) + "\n", nil
+}
+
+// Compact rewrites jsonStr on a single line, without whitespace, comments or
+// trailing commas.
+func Compact(jsonStr string) (string, error) {
+	f, err := newFormatter(jsonStr)
+	if err != nil {
+		return "", err
+	}
+	f.compact = true
+	f.format()
+	return string(f.buf), nil
+}
+
+// formatter writes the tokens of a document, comments included, back out.
+type formatter struct {
+	src     string
+	toks    []token.Token
+	i       int
+	last    token.Token // last token consumed
+	buf     []byte
+	indent  string
+	compact bool
+}
+
+func newFormatter(jsonStr string) (*formatter, error) {
+	if _, err := parse(jsonStr); err != nil {
+		return nil, err
+	}
+
+	l := lexer.NewLexer([]byte(jsonStr))
+	l.EmitComments()
+
+	f := &formatter{src: jsonStr}
+	for {
+		tok := l.NewToken()
+		if tok.Type == token.INVALID {
+			return nil, l.Err()
+		}
+		f.toks = append(f.toks, tok)
+		if tok.Type == token.EOF {
+			return f, nil
+		}
+	}
+}
+
+func (f *formatter) peek() token.Token {
+	return f.toks[f.i]
+}
+
+func (f *formatter) next() token.Token {
+	tok := f.toks[f.i]
+	if tok.Type != token.EOF {
+		f.i++
+	}
+	if tok.End.Offset > tok.Pos.Offset {
+		f.last = tok
+	}
+	return tok
+}
+
+// raw returns the source text of tok.
+func (f *formatter) raw(tok token.Token) string {
+	if tok.End.Offset > tok.Pos.Offset {
+		return f.src[tok.Pos.Offset:tok.End.Offset]
+	}
+	return string(tok.Lit)
+}
+
+// line starts a new line at the given depth, after an empty line when the
+// source has one before tok.
+func (f *formatter) line(depth int, tok token.Token) {
+	if f.compact {
+		return
+	}
+	if len(f.buf) > 0 {
+		f.buf = append(f.buf, '\n')
+		switch {
+		case f.last.Type == token.LBRACE || f.last.Type == token.LBRACKET:
+		case tok.Type == token.RBRACE || tok.Type == token.RBRACKET:
+		case tok.Pos.Line > f.last.End.Line+1:
+			f.buf = append(f.buf, '\n')
+		}
+	}
+	f.buf = append(f.buf, strings.Repeat(f.indent, depth)...)
+}
+
+// comment writes a comment on a line of its own.
+func (f *formatter) comment(depth int) {
+	tok := f.peek()
+	if !f.compact {
+		f.line(depth, tok)
+		f.buf = append(f.buf, f.raw(tok)...)
+	}
+	f.next()
+}
+
+func (f *formatter) format() {
+	for f.peek().Type == token.COMMENT {
+		f.comment(0)
+	}
+
+	if tok := f.peek(); tok.End.Offset > tok.Pos.Offset {
+		f.line(0, tok)
+	}
+	f.value(f.next(), 0)
+
+	for f.peek().Type == token.COMMENT {
+		if f.peek().Pos.Line == f.last.End.Line && !f.compact {
+			f.buf = append(f.buf, ' ')
+			f.buf = append(f.buf, f.raw(f.next())...)
+		} else {
+			f.comment(0)
+		}
+	}
+}
+
+func (f *formatter) value(tok token.Token, depth int) {
+	switch tok.Type {
+	case token.LBRACE, token.LBRACKET:
+		f.container(tok, depth)
+	default:
+		f.buf = append(f.buf, f.raw(tok)...)
+	}
+}
+
+// container writes an object or an array from its opening token up to and
+// including its closing one.
+func (f *formatter) container(open token.Token, depth int) {
+	closeType, closeChar := token.Type(token.RBRACKET), byte(']')
+	if open.Type == token.LBRACE {
+		closeType, closeChar = token.RBRACE, '}'
+	}
+
+	// Implied outer braces take no room in the source and are not written.
+	braceless := open.End.Offset == open.Pos.Offset
+	inner := depth + 1
+	if braceless {
+		inner = depth
+	} else {
+		f.buf = append(f.buf, f.raw(open)...)
+	}
+
+	empty := true
+	for {
+		for f.peek().Type == token.COMMENT {
+			f.comment(inner)
+			empty = false
+		}
+
+		tok := f.peek()
+		if tok.Type == closeType || tok.Type == token.EOF {
+			break
+		}
+		if tok.Type == token.COMMA {
+			f.next()
+			continue
+		}
+		empty = false
+		f.line(inner, tok)
+		f.next()
+
+		// Comments inside an entry move after it.
+		var trailing []token.Token
+		if open.Type == token.LBRACE {
+			f.buf = append(f.buf, f.raw(tok)...)
+			trailing = f.skipComments(trailing, false)
+			f.next() // ':'
+			f.buf = append(f.buf, ':')
+			if !f.compact {
+				f.buf = append(f.buf, ' ')
+			}
+			trailing = f.skipComments(trailing, false)
+			tok = f.next()
+		}
+		f.value(tok, inner)
+
+		trailing = f.skipComments(trailing, true)
+		if f.peek().Type == token.COMMA {
+			f.next()
+			trailing = f.skipComments(trailing, true)
+		}
+		if f.more(closeType) {
+			f.buf = append(f.buf, ',')
+		}
+		for _, comment := range trailing {
+			if !f.compact {
+				f.buf = append(f.buf, ' ')
+				f.buf = append(f.buf, f.raw(comment)...)
+			}
+		}
+	}
+
+	closing := f.next()
+	if braceless {
+		return
+	}
+	if !empty {
+		f.line(depth, closing)
+	}
+	f.buf = append(f.buf, closeChar)
+}
+
+// skipComments appends the comments at the cursor to trailing, only those on
+// the line of the last token when sameLine is set.
+func (f *formatter) skipComments(trailing []token.Token, sameLine bool) []token.Token {
+	for f.peek().Type == token.COMMENT && (!sameLine || f.peek().Pos.Line == f.last.End.Line) {
+		trailing = append(trailing, f.next())
+	}
+	return trailing
+}
+
+// more reports whether another entry follows before the closing token.
+func (f *formatter) more(closeType token.Type) bool {
+	for _, tok := range f.toks[f.i:] {
+		switch tok.Type {
+		case token.COMMENT, token.COMMA:
+			continue
+		case closeType, token.EOF:
+			return false
+		}
+		return true
+	}
+	return false
+}
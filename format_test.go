@@ -0,0 +1,68 @@
+package json
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		input   string
+		format  string
+		compact string
+	}{
+		{
+			"// header\n\nname: value, // the name\ncount: 3,\n\n/* block */\nlist: [1,2,{a:1,},],\nempty: {}, none: [ /* nothing */ ]\n// end\n",
+			"// header\n\nname: value, // the name\ncount: 3,\n\n/* block */\nlist: [\n\t1,\n\t2,\n\t{\n\t\ta: 1\n\t}\n],\n" +
+				"empty: {},\nnone: [\n\t/* nothing */\n]\n// end\n",
+			"name:value,count:3,list:[1,2,{a:1}],empty:{},none:[]",
+		},
+		{
+			`{"a":{"b":[1.50,"xé",null]},  "c" : true} // tail`,
+			"{\n\t\"a\": {\n\t\t\"b\": [\n\t\t\t1.50,\n\t\t\t\"xé\",\n\t\t\tnull\n\t\t]\n\t},\n\t\"c\": true\n} // tail\n",
+			`{"a":{"b":[1.50,"xé",null]},"c":true}`,
+		},
+		{
+			"[1, // one\n 2 /* two */, 3,\n\n 4,]",
+			"[\n\t1, // one\n\t2, /* two */\n\t3,\n\n\t4\n]\n",
+			"[1,2,3,4]",
+		},
+		{
+			"{a: // about a\n 1}",
+			"{\n\ta: 1 // about a\n}\n",
+			"{a:1}",
+		},
+		{
+			// Invalid UTF-8 is copied through byte for byte.
+			"{a: \"\xff\", b: 12345}",
+			"{\n\ta: \"\xff\",\n\tb: 12345\n}\n",
+			"{a:\"\xff\",b:12345}",
+		},
+		{
+			"{\"\xff\xff\xff\": 1, bb: 2}",
+			"{\n\t\"\xff\xff\xff\": 1,\n\tbb: 2\n}\n",
+			"{\"\xff\xff\xff\":1,bb:2}",
+		},
+		{"42", "42\n", "42"},
+		{"", "\n", ""},
+	}
+
+	for i, test := range tests {
+		got, err := Format(test.input, "\t")
+		if err != nil || got != test.format {
+			t.Fatalf("On test[%d], expected:\n%s\nGot:\n%s (%v)", i, test.format, got, err)
+		}
+		if again, _ := Format(got, "\t"); again != got {
+			t.Fatalf("On test[%d], expected Format to be stable, Got:\n%s", i, again)
+		}
+
+		compact, err := Compact(test.input)
+		if err != nil || compact != test.compact {
+			t.Fatalf("On test[%d], expected %s, Got=%s (%v)", i, test.compact, compact, err)
+		}
+	}
+
+	if _, err := Format("a: [1, 2", "  "); err == nil || !strings.Contains(err.Error(), "expected ',' or ']'") {
+		t.Fatalf("TestFormat expected a syntax error, Got=%v", err)
+	}
+}
@@ -1,402 +1,1292 @@
 package json
 
 import (
+	"encoding"
+	"encoding/base64"
 	"fmt"
+	"io"
+	"math"
+	"os"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
-	"github.com/qw20012/go-basic"
-	"github.com/qw20012/go-basic/ref"
-	"github.com/qw20012/go-basic/str"
 	"github.com/qw20012/go-json/lexer"
 	"github.com/qw20012/go-json/parser"
+	"github.com/qw20012/go-json/token"
 )
 
-func Parse(jsonStr string) *Container {
-	lexer := lexer.NewLexer([]byte(jsonStr))
-	parser := parser.NewParser(lexer)
-	ast := parser.Parse()
+// SyntaxError describes malformed input and the position where it was found.
+type SyntaxError = lexer.SyntaxError
+
+// Object is an object that keeps its keys in insertion order. Parsing with
+// KeepOrder yields objects as *Object; Marshal writes them in that order.
+type Object = parser.Object
+
+// NewObject returns an empty *Object.
+func NewObject() *Object {
+	return parser.NewObject()
+}
+
+// ParseOption configures the parser used by ParseE, ParseFile and
+// ParseDocument.
+type ParseOption func(*parser.Parser)
+
+// KeepOrder parses objects as *Object, keeping the keys in source order,
+// instead of map[string]any.
+func KeepOrder() ParseOption {
+	return func(p *parser.Parser) {
+		p.KeepOrder()
+	}
+}
+
+// DuplicatePolicy says what parsing does with a key that appears more than
+// once in an object; see DuplicateKeys.
+type DuplicatePolicy = parser.DuplicatePolicy
+
+const (
+	KeepLast          = parser.KeepLast          // the last value wins, the default
+	KeepFirst         = parser.KeepFirst         // the first value wins
+	RejectDuplicates  = parser.RejectDuplicates  // a *SyntaxError naming both positions
+	WarnDuplicates    = parser.WarnDuplicates    // the last value wins; see WarnDuplicateKeys
+	CollectDuplicates = parser.CollectDuplicates // the values are collected into an array, in order
+)
+
+// DuplicateKey describes a key repeated in an object.
+type DuplicateKey = parser.DuplicateKey
+
+// DuplicateKeys sets the policy for keys repeated in an object.
+func DuplicateKeys(policy DuplicatePolicy) ParseOption {
+	return func(p *parser.Parser) {
+		p.DuplicateKeys(policy, nil)
+	}
+}
+
+// WarnDuplicateKeys lets the last value of a repeated key win, as by default,
+// and calls warn for every repetition.
+func WarnDuplicateKeys(warn func(DuplicateKey)) ParseOption {
+	return func(p *parser.Parser) {
+		p.DuplicateKeys(WarnDuplicates, warn)
+	}
+}
+
+// ParseE parses jsonStr into a Container. Malformed input is reported as a
+// *SyntaxError.
+func ParseE(jsonStr string, opts ...ParseOption) (*Container, error) {
+	ast, err := parse(jsonStr, opts...)
+	if err != nil {
+		return nil, err
+	}
 
 	var json = Container{object: ast}
 
-	return &json
+	return &json, nil
 }
 
-func Unmarshal[T any](jsonStr string) T {
+// Parse is like ParseE but returns nil when jsonStr is malformed.
+func Parse(jsonStr string, opts ...ParseOption) *Container {
+	json, _ := ParseE(jsonStr, opts...)
+	return json
+}
+
+func parse(jsonStr string, opts ...ParseOption) (any, error) {
+	return newParser(jsonStr, opts...).Parse()
+}
+
+func newParser(jsonStr string, opts ...ParseOption) *parser.Parser {
 	lexer := lexer.NewLexer([]byte(jsonStr))
 	parser := parser.NewParser(lexer)
-	//fmt.Println("ast")
-	ast := parser.Parse()
-	//fmt.Println(ast)
+	for _, opt := range opts {
+		opt(parser)
+	}
+	return parser
+}
+
+// UnmarshalE unmarshals jsonStr into the given generic type (T). Malformed
+// input is reported as a *SyntaxError, a value that does not fit its Go type
+// as an *UnmarshalTypeError, and unknown or missing struct fields as a
+// *FieldError.
+func UnmarshalE[T any](jsonStr string, opts ...DecodeOption) (T, error) {
+	ast, err := parse(jsonStr)
+	if err != nil {
+		var hold T
+		return hold, err
+	}
+	return build[T](ast, jsonStr, opts...)
+}
+
+// Unmarshal is like UnmarshalE but ignores errors, returning what could be
+// unmarshalled before the first one.
+func Unmarshal[T any](jsonStr string) T {
+	hold, _ := UnmarshalE[T](jsonStr)
+	return hold
+}
+
+// ParseFile parses the content of the named file into a Container. A
+// *SyntaxError returned for malformed content names the file.
+func ParseFile(path string, opts ...ParseOption) (*Container, error) {
+	ast, err := parseFile(path, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var json = Container{object: ast}
+
+	return &json, nil
+}
+
+// UnmarshalFile unmarshals the content of the named file into the given
+// generic type (T). This can be used to process configuration files. The
+// errors of UnmarshalE name the file.
+func UnmarshalFile[T any](path string, opts ...DecodeOption) (T, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		var hold T
+		return hold, err
+	}
+	hold, err := UnmarshalE[T](string(content), opts...)
+	return hold, withFile(err, path)
+}
+
+// MarshalFile marshals source and writes the result to the named file,
+// creating it if necessary.
+func MarshalFile(path string, source any, opts ...EncodeOption) error {
+	json, err := MarshalE(source, opts...)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(json), 0644)
+}
+
+func parseFile(path string, opts ...ParseOption) (any, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	ast, err := parse(string(content), opts...)
+	return ast, withFile(err, path)
+}
+
+// withFile names the file in an error that has a place for it.
+func withFile(err error, path string) error {
+	switch e := err.(type) {
+	case *SyntaxError:
+		e.File = path
+	case *UnmarshalTypeError:
+		e.File = path
+	case *FieldError:
+		e.File = path
+	}
+	return err
+}
+
+// location formats the file and position of an error as a prefix, the way
+// a *SyntaxError does, e.g. "config.json:3:7: ".
+func location(file string, pos token.Pos) string {
+	switch {
+	case pos.Line > 0 && file != "":
+		return fmt.Sprintf("%s:%s: ", file, pos)
+	case pos.Line > 0:
+		return pos.String() + ": "
+	case file != "":
+		return file + ": "
+	}
+	return ""
+}
+
+// UnmarshalTypeError describes a value that could not be stored in a Go value
+// of the given type.
+type UnmarshalTypeError struct {
+	Value string       // description of the value, e.g. "string \"abc\""
+	Type  reflect.Type // type of the Go value it could not be assigned to
+	Path  string       // dot path of the value, empty for the top level value
+	File  string       // name of the input, if known
+	Pos   token.Pos    // where the value starts, if known
+}
+
+func (e *UnmarshalTypeError) Error() string {
+	msg := fmt.Sprintf("cannot unmarshal %s into Go value of type %s", e.Value, e.Type)
+	if e.Path != "" {
+		msg += " at " + e.Path
+	}
+	return location(e.File, e.Pos) + msg
+}
+
+// UnknownField is an object key that matches no field of its struct.
+type UnknownField struct {
+	Path       string    // dot path of the key
+	Suggestion string    // closest field name, empty when none is close
+	Pos        token.Pos // where the key starts, if known
+}
+
+// FieldError lists the unknown keys rejected by RejectUnknown and the
+// required fields missing from the input.
+type FieldError struct {
+	Unknown []UnknownField
+	Missing []string // dot paths of the missing fields
+	File    string   // name of the input, if known
+}
+
+func (e *FieldError) Error() string {
+	var problems []string
+	for _, unknown := range e.Unknown {
+		problem := location(e.File, unknown.Pos) + fmt.Sprintf("unknown field %s", unknown.Path)
+		if unknown.Suggestion != "" {
+			problem += fmt.Sprintf(" (did you mean %s?)", unknown.Suggestion)
+		}
+		problems = append(problems, problem)
+	}
+	for _, missing := range e.Missing {
+		problems = append(problems, location(e.File, token.Pos{})+"missing required field "+missing)
+	}
+	return strings.Join(problems, "; ")
+}
+
+// UnknownFieldPolicy decides what Unmarshal does with object keys that match
+// no field of the struct they are decoded into.
+type UnknownFieldPolicy int
+
+const (
+	// IgnoreUnknown drops unknown keys. This is the default.
+	IgnoreUnknown UnknownFieldPolicy = iota
+	// CollectUnknown stores unknown keys in the map field tagged
+	// `json:",unknown"`, and drops them when the struct has none.
+	CollectUnknown
+	// RejectUnknown fails with a *FieldError listing every unknown key.
+	RejectUnknown
+)
+
+// DecodeOption configures UnmarshalE and UnmarshalFile.
+type DecodeOption func(*decodeState)
+
+// UnknownFields sets the policy for object keys without a struct field.
+func UnknownFields(policy UnknownFieldPolicy) DecodeOption {
+	return func(d *decodeState) {
+		d.unknown = policy
+	}
+}
+
+// decodeState carries the location of the value being built, for errors.
+type decodeState struct {
+	path     []string
+	unknown  UnknownFieldPolicy
+	fieldErr FieldError
+
+	// src is the text ast was parsed from, if known. Its syntax tree gives
+	// the positions of values in errors, and is parsed at the first error.
+	src string
+	doc *document
+}
+
+func build[T any](ast any, src string, opts ...DecodeOption) (T, error) {
 	var hold T
+	err := buildInto(reflect.ValueOf(&hold).Elem(), ast, src, opts)
+	return hold, err
+}
+
+// buildInto stores ast, parsed from src when it is known, in v, reporting
+// unknown and missing fields once the whole value has been built.
+func buildInto(v reflect.Value, ast any, src string, opts []DecodeOption) error {
+	d := decodeState{src: src}
+	for _, opt := range opts {
+		opt(&d)
+	}
+
+	if err := d.buildValue(v, ast); err != nil {
+		return err
+	}
+	if len(d.fieldErr.Unknown) > 0 || len(d.fieldErr.Missing) > 0 {
+		sort.Slice(d.fieldErr.Unknown, func(i, j int) bool {
+			return d.fieldErr.Unknown[i].Path < d.fieldErr.Unknown[j].Path
+		})
+		sort.Strings(d.fieldErr.Missing)
+		return &d.fieldErr
+	}
+	return nil
+}
+
+// position returns where the value at hierarchy, or its key, starts in the
+// source, or the zero Pos when the source is not known.
+func (d *decodeState) position(hierarchy []string, key bool) token.Pos {
+	if d.src == "" {
+		return token.Pos{}
+	}
+	if d.doc == nil {
+		doc, err := newDocument(d.src, false)
+		if err != nil {
+			d.src = ""
+			return token.Pos{}
+		}
+		d.doc = doc
+	}
+	return d.doc.position(hierarchy, key)
+}
+
+// pathOf returns the dot path of key inside the value being built.
+func (d *decodeState) pathOf(key string) string {
+	return strings.Join(append(d.path[:len(d.path):len(d.path)], key), ".")
+}
+
+func (d *decodeState) typeError(ast any, ty reflect.Type) error {
+	value := "null"
+	switch t := ast.(type) {
+	case string:
+		value = fmt.Sprintf("string %q", t)
+	case int64, uint64, float64:
+		value = fmt.Sprintf("number %v", t)
+	case bool:
+		value = fmt.Sprintf("bool %v", t)
+	case map[string]any:
+		value = "object"
+	case []any:
+		value = "array"
+	}
+	return &UnmarshalTypeError{Value: value, Type: ty, Path: strings.Join(d.path, "."),
+		Pos: d.position(d.path, false)}
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
 
-	ty := reflect.TypeOf(hold)
-	switch ty.Kind() {
+// buildValue stores ast, as produced by the parser, in v. A null sets a
+// pointer, map, slice or interface to nil and leaves any other value as it
+// is, unless it implements Unmarshaler. Types implementing Unmarshaler or
+// encoding.TextUnmarshaler decode themselves.
+func (d *decodeState) buildValue(v reflect.Value, ast any) error {
+	if ast == nil {
+		switch v.Kind() {
+		case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+			v.Set(reflect.Zero(v.Type()))
+		default:
+			if _, ok := hook(v, unmarshalerType); ok && v.Type() != timeType {
+				_, err := d.buildUnmarshaler(v, ast)
+				return err
+			}
+		}
+		return nil
+	}
+
+	if v.Type() == timeType {
+		return d.buildTime(v, ast)
+	}
+	if ok, err := d.buildUnmarshaler(v, ast); ok {
+		return err
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return d.buildValue(v.Elem(), ast)
+	case reflect.Interface:
+		return d.buildInterface(v, ast)
 	case reflect.Map:
-		//return buildMap(ty, ast).Interface().(T)
-		return ref.GetValue[T](buildMap(ty, ast))
+		return d.buildMap(v, ast)
 	case reflect.Slice:
-		//return buildSlice(ty, ast).Interface().(T)
-		return ref.GetValue[T](buildSlice(ty, ast))
+		return d.buildSlice(v, ast)
+	case reflect.Array:
+		return d.buildArray(v, ast)
 	case reflect.Struct:
-		//return buildStruct(ty, ast).Interface().(T)
-		return ref.GetValue[T](buildStruct(ty, ast))
-	case reflect.String:
-		//v := reflect.ValueOf(ast.(string))
-		//return v.Interface().(T)
+		return d.buildStruct(v, ast)
+	}
+	return d.buildBasic(v, ast)
+}
 
-		strValue := basic.FromAny[string](ast)
-		v := reflect.ValueOf(strValue)
-		return ref.GetValue[T](v)
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		strValue := basic.FromAny[string](ast)
-		if i, err := strconv.Atoi(strValue); err == nil {
-			v := reflect.ValueOf(i)
-			return ref.GetValue[T](v)
-			//return v.Interface().(T)
+// buildInterface stores ast in an empty interface as it is. An interface that
+// already holds a non-nil pointer is decoded into instead, as in encoding/json.
+func (d *decodeState) buildInterface(v reflect.Value, ast any) error {
+	if !v.IsNil() && v.Elem().Kind() == reflect.Ptr && !v.Elem().IsNil() {
+		return d.buildValue(v.Elem(), ast)
+	}
+
+	if v.NumMethod() != 0 {
+		return d.typeError(ast, v.Type())
+	}
+	v.Set(reflect.ValueOf(ast))
+	return nil
+}
+
+func (d *decodeState) buildTime(v reflect.Value, ast any) error {
+	text, ok := ast.(string)
+	if !ok {
+		return d.typeError(ast, v.Type())
+	}
+
+	t, err := time.Parse(time.RFC3339, text)
+	if err != nil {
+		return d.typeError(ast, v.Type())
+	}
+	v.Set(reflect.ValueOf(t))
+	return nil
+}
+
+// buildMap stores an object in a map, adding to the map if it already exists.
+// Keys are converted to the key type of the map: strings, integers or types
+// implementing encoding.TextUnmarshaler.
+func (d *decodeState) buildMap(v reflect.Value, ast any) error {
+	mapObject, ok := ast.(map[string]any)
+	if !ok {
+		return d.typeError(ast, v.Type())
+	}
+
+	keyType := v.Type().Key()
+	switch keyType.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+	default:
+		if !reflect.PtrTo(keyType).Implements(textUnmarshalerType) {
+			return d.typeError(ast, v.Type())
 		}
-	case reflect.Bool:
-		strValue := basic.FromAny[string](ast)
-		if realValue, err := strconv.ParseBool(strValue); err == nil {
-			v := reflect.ValueOf(realValue)
-			//return v.Interface().(T)
-			return ref.GetValue[T](v)
+	}
+
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(v.Type()))
+	}
+
+	for key, value := range mapObject {
+		d.path = append(d.path, key)
+
+		mapKey, err := d.buildMapKey(keyType, key)
+		if err == nil {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err = d.buildValue(elem, value); err == nil {
+				v.SetMapIndex(mapKey, elem)
+			}
 		}
-	case reflect.Float32:
-		strValue := basic.FromAny[string](ast)
-		if realValue, err := strconv.ParseFloat(strValue, 32); err == nil {
-			v := reflect.ValueOf(realValue)
-			//return v.Interface().(T)
-			return ref.GetValue[T](v)
+
+		d.path = d.path[:len(d.path)-1]
+		if err != nil {
+			return err
 		}
-	case reflect.Float64:
-		strValue := basic.FromAny[string](ast)
-		if realValue, err := strconv.ParseFloat(strValue, 64); err == nil {
-			v := reflect.ValueOf(realValue)
-			//return v.Interface().(T)
-			return ref.GetValue[T](v)
+	}
+	return nil
+}
+
+func (d *decodeState) buildMapKey(keyType reflect.Type, key string) (reflect.Value, error) {
+	mapKey := reflect.New(keyType)
+	if unmarshaler, ok := mapKey.Interface().(encoding.TextUnmarshaler); ok {
+		if err := unmarshaler.UnmarshalText([]byte(key)); err != nil {
+			return mapKey, err
 		}
+		return mapKey.Elem(), nil
 	}
 
-	return hold
+	mapKey = mapKey.Elem()
+	switch keyType.Kind() {
+	case reflect.String:
+		mapKey.SetString(key)
+		return mapKey, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(key, 10, 64)
+		if err == nil && !mapKey.OverflowInt(i) {
+			mapKey.SetInt(i)
+			return mapKey, nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := strconv.ParseUint(key, 10, 64)
+		if err == nil && !mapKey.OverflowUint(u) {
+			mapKey.SetUint(u)
+			return mapKey, nil
+		}
+	}
+	return mapKey, &UnmarshalTypeError{Value: fmt.Sprintf("object key %q", key), Type: keyType,
+		Path: strings.Join(d.path, "."), Pos: d.position(d.path, true)}
 }
 
-func buildMap(ty reflect.Type, ast any) reflect.Value {
-	v := reflect.MakeMap(ty)
-	if mapObject, ok := ast.(map[string]any); ok {
-		if ty.Elem().Kind() == reflect.Interface {
-			v = reflect.ValueOf(mapObject)
-			return v
-		}
-
-		for key, value := range mapObject {
-			strValue := value.(string)
-			switch ty.Elem().Kind() {
-			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-				if realValue, err := strconv.Atoi(strValue); err == nil {
-					v.SetMapIndex(reflect.ValueOf(key), reflect.ValueOf(realValue))
-				}
-			case reflect.String:
-				v.SetMapIndex(reflect.ValueOf(key), reflect.ValueOf(strValue))
-			case reflect.Bool:
-				if realValue, err := strconv.ParseBool(strValue); err == nil {
-					v.SetMapIndex(reflect.ValueOf(key), reflect.ValueOf(realValue))
-				}
-			case reflect.Float32:
-				if realValue, err := strconv.ParseFloat(strValue, 32); err == nil {
-					v.SetMapIndex(reflect.ValueOf(key), reflect.ValueOf(realValue))
-				}
-			case reflect.Float64:
-				if realValue, err := strconv.ParseFloat(strValue, 64); err == nil {
-					v.SetMapIndex(reflect.ValueOf(key), reflect.ValueOf(realValue))
-				}
+// buildStruct stores an object in a struct. Keys match the field names
+// resolved from the json tags, exactly or else case-insensitively; other keys
+// are handled as the UnknownFieldPolicy says. Required fields whose key is
+// missing are recorded for the final *FieldError.
+func (d *decodeState) buildStruct(v reflect.Value, ast any) error {
+	mapObject, ok := ast.(map[string]any)
+	if !ok {
+		return d.typeError(ast, v.Type())
+	}
+
+	fields := cachedFields(v.Type())
+	seen := map[string]bool{}
+	for key, value := range mapObject {
+		f := fields.lookup(key)
+		if f == nil {
+			if err := d.buildUnknown(v, fields, key, value); err != nil {
+				return err
 			}
+			continue
 		}
-	}
-	return v
-}
+		seen[f.name] = true
 
-func buildStruct(ty reflect.Type, ast any) reflect.Value {
-	v := reflect.New(ty)
+		d.path = append(d.path, key)
+		field, _ := fieldByIndex(v, f.index, true)
+		var err error
+		if f.quoted {
+			err = d.buildQuoted(field, value)
+		} else {
+			err = d.buildValue(field, value)
+		}
+		d.path = d.path[:len(d.path)-1]
+		if err != nil {
+			return err
+		}
+	}
 
-	if v.Kind() == reflect.Ptr {
-		v = reflect.Indirect(v)
+	for _, f := range fields.list {
+		if f.required && !seen[f.name] {
+			d.fieldErr.Missing = append(d.fieldErr.Missing, d.pathOf(f.name))
+		}
 	}
+	return nil
+}
 
-	if mapObject, ok := ast.(map[string]any); ok {
-		for key, value := range mapObject {
-			field := v.FieldByName(key)
+// buildUnknown handles a key of struct v that matches no field.
+func (d *decodeState) buildUnknown(v reflect.Value, fields *structFields, key string, value any) error {
+	switch d.unknown {
+	case RejectUnknown:
+		d.fieldErr.Unknown = append(d.fieldErr.Unknown,
+			UnknownField{Path: d.pathOf(key), Suggestion: fields.closestName(key),
+				Pos: d.position(append(d.path[:len(d.path):len(d.path)], key), true)})
+	case CollectUnknown:
+		if fields.unknown == nil {
+			return nil
+		}
 
-			if field.Type().Kind() == reflect.Ptr {
-				elemType := field.Type().Elem()
+		catchAll, _ := fieldByIndex(v, fields.unknown, true)
+		if catchAll.IsNil() {
+			catchAll.Set(reflect.MakeMap(catchAll.Type()))
+		}
 
-				if elemType.Kind() == reflect.Struct {
-					field.Set(buildStruct(elemType, value).Addr())
-					continue
-				} else if elemType.Kind() == reflect.Slice {
-					slicePtrVal := reflect.New(elemType)
-					sliceVal := reflect.Indirect(slicePtrVal)
-					sliceVal.Set(buildSlice(elemType, value))
-					field.Set(slicePtrVal)
+		d.path = append(d.path, key)
+		elem := reflect.New(catchAll.Type().Elem()).Elem()
+		err := d.buildValue(elem, value)
+		d.path = d.path[:len(d.path)-1]
+		if err != nil {
+			return err
+		}
 
-					continue
-				}
+		mapKey := reflect.New(catchAll.Type().Key()).Elem()
+		mapKey.SetString(key)
+		catchAll.SetMapIndex(mapKey, elem)
+	}
+	return nil
+}
 
-				ref.SetStructBasicPtrField(field, value)
-				continue
-			}
+// buildQuoted stores the value of a field tagged ",string". A string field
+// expects a quoted JSON string inside the string, and a bool or numeric field
+// the JSON literal; other fields ignore the option.
+func (d *decodeState) buildQuoted(v reflect.Value, ast any) error {
+	text, ok := ast.(string)
+	if !ok {
+		return d.buildValue(v, ast)
+	}
+
+	elem := v
+	for elem.Kind() == reflect.Ptr {
+		if elem.IsNil() {
+			elem.Set(reflect.New(elem.Type().Elem()))
+		}
+		elem = elem.Elem()
+	}
 
-			if field.Type().Kind() == reflect.Struct {
-				field.Set(buildStruct(field.Type(), value))
-				continue
-			} else if field.Type().Kind() == reflect.Slice {
-				field.Set(buildSlice(field.Type(), value))
-				continue
+	inner, err := parse(text)
+	switch elem.Kind() {
+	case reflect.String:
+		if s, ok := inner.(string); err == nil && ok && strings.HasPrefix(strings.TrimSpace(text), `"`) {
+			elem.SetString(s)
+			return nil
+		}
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		switch inner.(type) {
+		case bool, int64, uint64, float64:
+			if err == nil && d.buildBasic(elem, inner) == nil {
+				return nil
 			}
+		}
+	default:
+		return d.buildValue(elem, ast)
+	}
+	return d.typeError(ast, v.Type())
+}
 
-			ref.SetStructBasicField(field, value)
+// buildSlice stores an array in a slice. A []byte also accepts a base64
+// string, as written by Marshal.
+func (d *decodeState) buildSlice(v reflect.Value, ast any) error {
+	if text, ok := ast.(string); ok && v.Type().Elem().Kind() == reflect.Uint8 {
+		bytes, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return d.typeError(ast, v.Type())
 		}
+		v.SetBytes(bytes)
+		return nil
+	}
+
+	array, ok := ast.([]any)
+	if !ok {
+		return d.typeError(ast, v.Type())
 	}
-	return v
+
+	aSlice := reflect.MakeSlice(v.Type(), len(array), len(array))
+	if err := d.buildElems(aSlice, array); err != nil {
+		return err
+	}
+
+	v.Set(aSlice)
+	return nil
 }
 
-func buildSlice(ty reflect.Type, ast any) reflect.Value {
-	v := reflect.ValueOf(ast.([]any))
-	if ty.Elem().Kind() == reflect.Interface {
-		return v
+// buildArray stores an array in a Go array. Extra elements are dropped and
+// missing ones are set to zero values, as in encoding/json.
+func (d *decodeState) buildArray(v reflect.Value, ast any) error {
+	array, ok := ast.([]any)
+	if !ok {
+		return d.typeError(ast, v.Type())
 	}
 
-	newCap := (v.Cap() + 1) * 2
+	if len(array) > v.Len() {
+		array = array[:v.Len()]
+	}
+	for i := len(array); i < v.Len(); i++ {
+		v.Index(i).Set(reflect.Zero(v.Type().Elem()))
+	}
+	return d.buildElems(v, array)
+}
 
-	aSlice := reflect.MakeSlice(ty, 0, newCap)
-	//t = reflect.Indirect(t)
-	for i := 0; i < v.Len(); i++ {
-		if ty.Elem().Kind() == reflect.Struct {
-			aSlice = reflect.Append(aSlice, buildStruct(ty.Elem(), v.Index(i).Interface()))
-			continue
-		} else if ty.Elem().Kind() == reflect.Ptr {
-			aSlice = ref.AppendSliceBasicPtrElem(aSlice, v.Index(i))
-			continue
+func (d *decodeState) buildElems(v reflect.Value, array []any) error {
+	for i, value := range array {
+		d.path = append(d.path, strconv.Itoa(i))
+		err := d.buildValue(v.Index(i), value)
+		d.path = d.path[:len(d.path)-1]
+		if err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		aSlice = ref.AppendSliceBasicElem(aSlice, v.Index(i))
+// buildBasic stores a scalar in v. A string goes into a string only; numbers
+// and booleans carried in strings need the string tag option.
+func (d *decodeState) buildBasic(v reflect.Value, ast any) error {
+	switch v.Kind() {
+	case reflect.String:
+		if realValue, ok := ast.(string); ok {
+			v.SetString(realValue)
+			return nil
+		}
+	case reflect.Bool:
+		switch realValue := ast.(type) {
+		case bool:
+			v.SetBool(realValue)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		var ok bool
+		switch realValue := ast.(type) {
+		case int64:
+			i, ok = realValue, true
+		case float64:
+			i, ok = int64(realValue), realValue == float64(int64(realValue))
+		}
+		if ok && !v.OverflowInt(i) {
+			v.SetInt(i)
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var u uint64
+		var ok bool
+		switch realValue := ast.(type) {
+		case int64:
+			u, ok = uint64(realValue), realValue >= 0
+		case uint64:
+			u, ok = realValue, true
+		case float64:
+			u, ok = uint64(realValue), realValue >= 0 && realValue == float64(uint64(realValue))
+		}
+		if ok && !v.OverflowUint(u) {
+			v.SetUint(u)
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		var ok bool
+		switch realValue := ast.(type) {
+		case int64:
+			f, ok = float64(realValue), true
+		case uint64:
+			f, ok = float64(realValue), true
+		case float64:
+			f, ok = realValue, true
+		}
+		if ok && !v.OverflowFloat(f) {
+			v.SetFloat(f)
+			return nil
+		}
 	}
+	return d.typeError(ast, v.Type())
+}
+
+// EncodeOption configures the output of Marshal.
+type EncodeOption func(*encodeState)
 
-	return aSlice
+// Human makes Marshal leave the quotes off keys and strings wherever the
+// relaxed syntax of this package reads them back unchanged. The output is
+// meant for people and for Parse/Unmarshal; it is not RFC 8259 JSON.
+func Human() EncodeOption {
+	return func(e *encodeState) {
+		e.human = true
+	}
 }
 
-func Marshal(source any) string {
-	json := ""
-	ty := reflect.TypeOf(source)
-	val := reflect.ValueOf(source)
-	switch ty.Kind() {
-	case reflect.Struct:
-		json = parseStruct(ty, val)
-	case reflect.Map:
-		json = parseMap(val)
-	case reflect.Ptr:
-		json = parsePtr(ty, val)
+// Indent makes Marshal put every element of an object or array on a new line
+// that begins with prefix followed by one copy of indent per level of nesting,
+// as encoding/json.MarshalIndent does.
+func Indent(prefix, indent string) EncodeOption {
+	return func(e *encodeState) {
+		e.prefix, e.indent = prefix, indent
+		e.indented = prefix != "" || indent != ""
 	}
+}
 
-	return json
+// EscapeHTML sets whether '<', '>' and '&' in strings are escaped so that the
+// output is safe to embed in HTML. They are escaped by default.
+func EscapeHTML(on bool) EncodeOption {
+	return func(e *encodeState) {
+		e.escapeHTML = on
+	}
 }
 
-func parsePtr(ty reflect.Type, value reflect.Value) string {
-	json := ""
+// UnsupportedValueError is returned by MarshalE for a value that has no JSON
+// representation, such as a NaN float.
+type UnsupportedValueError struct {
+	Value reflect.Value
+	Str   string
+}
 
-	if value.IsNil() {
-		return "nil"
-	}
+func (e *UnsupportedValueError) Error() string {
+	return "unsupported value: " + e.Str
+}
 
-	ty = ty.Elem()
-	value = value.Elem()
-	val := reflect.Indirect(value)
+// UnsupportedTypeError is returned by MarshalE for a value of a type that
+// cannot be marshalled.
+type UnsupportedTypeError struct {
+	Type reflect.Type
+}
 
-	switch val.Type().Kind() {
-	case reflect.Struct:
-		json = parseStruct(ty, value)
+func (e *UnsupportedTypeError) Error() string {
+	return "unsupported type: " + e.Type.String()
+}
 
-	default:
+// encodeState accumulates the output of Marshal. With a writer, complete
+// elements are flushed to it as the buffer fills up.
+type encodeState struct {
+	buf        []byte
+	human      bool
+	escapeHTML bool
+
+	indented bool
+	prefix   string
+	indent   string
+	depth    int
 
+	w io.Writer
+}
+
+var encodeStatePool sync.Pool
+
+// newEncodeState returns an encodeState with default settings, reusing the
+// buffer of a released one.
+func newEncodeState() *encodeState {
+	if pooled, ok := encodeStatePool.Get().(*encodeState); ok {
+		*pooled = encodeState{buf: pooled.buf[:0], escapeHTML: true}
+		return pooled
 	}
+	return &encodeState{escapeHTML: true}
+}
 
-	return json
+// release returns e to the pool. Very large buffers are left to the garbage
+// collector rather than kept alive.
+func (e *encodeState) release() {
+	if cap(e.buf) <= 1<<20 {
+		e.w = nil
+		encodeStatePool.Put(e)
+	}
 }
 
-func parseMap(val reflect.Value) string {
-	json := ""
-	for _, e := range val.MapKeys() {
-		v := val.MapIndex(e)
-		switch t := v.Interface().(type) {
-		case int, int8, int16, int32, int64, float32, float64:
+// flushSize is the amount of output an encodeState with a writer buffers.
+const flushSize = 32 << 10
 
-			json = str.Contact(json, e, ":", t, ",")
-		case string:
+// flush writes the buffered output to the writer, if there is one and the
+// buffer holds at least limit bytes.
+func (e *encodeState) flush(limit int) error {
+	if e.w == nil || len(e.buf) < limit {
+		return nil
+	}
+	_, err := e.w.Write(e.buf)
+	e.buf = e.buf[:0]
+	return err
+}
 
-			json = str.Contact(json, e, ":", t, ",")
-		case bool:
+// newline starts a new line at the current depth when indenting.
+func (e *encodeState) newline() {
+	if !e.indented {
+		return
+	}
+	e.buf = append(e.buf, '\n')
+	e.buf = append(e.buf, e.prefix...)
+	for i := 0; i < e.depth; i++ {
+		e.buf = append(e.buf, e.indent...)
+	}
+}
 
-			json = str.Contact(json, e, ":", t, ",")
-		case map[string]any:
-			json = str.Contact(json, e, ":", parseMap(reflect.ValueOf(t)), ",")
-		default:
-			fmt.Println("not found")
-		}
+// colon separates a key from its value.
+func (e *encodeState) colon() {
+	e.buf = append(e.buf, ':')
+	if e.indented {
+		e.buf = append(e.buf, ' ')
+	}
+}
+
+// MarshalE marshals source into RFC 8259 JSON that encoding/json accepts, or
+// into the relaxed syntax of this package with the Human option.
+func MarshalE(source any, opts ...EncodeOption) (string, error) {
+	e := newEncodeState()
+	defer e.release()
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	if err := e.marshal(reflect.ValueOf(source)); err != nil {
+		return "", err
 	}
-	json = removeLastComma(json)
-	json = addBrace(json)
+	return string(e.buf), nil
+}
+
+// Marshal is like MarshalE but returns an empty string on error.
+func Marshal(source any, opts ...EncodeOption) string {
+	json, _ := MarshalE(source, opts...)
 	return json
 }
 
-func parseStruct(ty reflect.Type, value reflect.Value) string {
-	json := ""
-	if ty.Kind() == reflect.Ptr {
+var (
+	timeType   = reflect.TypeOf(time.Time{})
+	objectType = reflect.TypeOf((*Object)(nil))
+)
+
+func (e *encodeState) marshal(value reflect.Value) error {
+	if !value.IsValid() {
+		e.buf = append(e.buf, "null"...)
+		return nil
+	}
+
+	if value.Type() == timeType {
+		e.parseString(value.Interface().(time.Time).Format(time.RFC3339Nano))
+		return nil
+	}
+	if value.Type() == objectType {
 		if value.IsNil() {
-			return "nil"
+			e.buf = append(e.buf, "null"...)
+			return nil
 		}
-		//(val.Field(i).Type().Kind() == reflect.Ptr && val.Field(i).Type().Elem().Kind() == reflect.Struct)
-		ty = ty.Elem()
-		value = value.Elem()
+		return e.parseObject(value.Interface().(*Object))
+	}
+	if ok, err := e.parseMarshaler(value); ok {
+		return err
 	}
 
-	val := reflect.Indirect(value)
+	switch value.Kind() {
+	case reflect.Bool:
+		e.buf = strconv.AppendBool(e.buf, value.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.buf = strconv.AppendInt(e.buf, value.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.buf = strconv.AppendUint(e.buf, value.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return e.parseFloat(value)
+	case reflect.String:
+		e.parseString(value.String())
+	case reflect.Ptr, reflect.Interface:
+		return e.parsePtr(value)
+	case reflect.Struct:
+		return e.parseStruct(value)
+	case reflect.Map:
+		return e.parseMap(value)
+	case reflect.Slice, reflect.Array:
+		return e.parseSlice(value)
+	default:
+		return &UnsupportedTypeError{Type: value.Type()}
+	}
+	return nil
+}
 
-	for i := 0; i < val.NumField(); i++ {
-		fieldName := ty.Field(i).Tag.Get("json")
-		if str.IsEmpty(fieldName) {
-			fieldName = ty.Field(i).Name
-		}
+// parseFloat formats floats the way encoding/json does, switching to
+// exponent notation for very small and very large values.
+func (e *encodeState) parseFloat(value reflect.Value) error {
+	f := value.Float()
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return &UnsupportedValueError{Value: value, Str: strconv.FormatFloat(f, 'g', -1, 64)}
+	}
 
-		switch val.Field(i).Type().Kind() {
-		case reflect.Struct:
-			json = str.Contact(json, fieldName, ":", parseStruct(val.Field(i).Type(), val.Field(i)), ",")
+	bits := value.Type().Bits()
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) ||
+			bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
 
-		case reflect.Slice, reflect.Array:
-			json = str.Contact(json, fieldName, ":", parseSlice(val.Field(i)), ",")
-		case reflect.Ptr:
+	e.buf = strconv.AppendFloat(e.buf, f, format, -1, bits)
+	if format == 'e' {
+		// clean up e-09 to e-9
+		n := len(e.buf)
+		if n >= 4 && e.buf[n-4] == 'e' && e.buf[n-3] == '-' && e.buf[n-2] == '0' {
+			e.buf[n-2] = e.buf[n-1]
+			e.buf = e.buf[:n-1]
+		}
+	}
+	return nil
+}
 
-			fieldElem := val.Field(i).Elem()
+func (e *encodeState) parseString(s string) {
+	if e.human && isBare(s) {
+		e.buf = append(e.buf, s...)
+		return
+	}
+	e.buf = appendQuoted(e.buf, s, e.escapeHTML)
+}
 
-			if !fieldElem.IsValid() {
-				continue
-			}
+func (e *encodeState) parsePtr(value reflect.Value) error {
+	if value.IsNil() {
+		e.buf = append(e.buf, "null"...)
+		return nil
+	}
+	return e.marshal(value.Elem())
+}
 
-			if fieldElem.Type().Kind() == reflect.Struct {
-				json = str.Contact(json, fieldName, ":", parseStruct(fieldElem.Type(), fieldElem), ",")
-				continue
-			} else if fieldElem.Type().Kind() == reflect.Slice {
-				json = str.Contact(json, fieldName, ":", parseSlice(fieldElem), ",")
-				continue
-			}
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
 
-			prtValue := parseStructFieldPtr(fieldElem, fieldName)
-			json = str.Contact(json, prtValue)
-		default:
-			json = str.Contact(json, fieldName, ":", val.Field(i), ",")
+// parseMap writes a map as an object with its keys sorted. Keys may be
+// strings, integers or implement encoding.TextMarshaler, as in encoding/json.
+func (e *encodeState) parseMap(value reflect.Value) error {
+	keyType := value.Type().Key()
+	switch keyType.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+	default:
+		if !keyType.Implements(textMarshalerType) {
+			return &UnsupportedTypeError{Type: value.Type()}
 		}
 	}
 
-	json = removeLastComma(json)
+	if value.IsNil() {
+		e.buf = append(e.buf, "null"...)
+		return nil
+	}
 
-	json = addBrace(json)
+	type entry struct {
+		name string
+		key  reflect.Value
+	}
+	entries := make([]entry, 0, value.Len())
+	for _, key := range value.MapKeys() {
+		name, err := mapKey(key)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry{name, key})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
 
-	return json
+	e.buf = append(e.buf, '{')
+	e.depth++
+	for i, entry := range entries {
+		if i > 0 {
+			e.buf = append(e.buf, ',')
+		}
+		e.newline()
+		e.parseString(entry.name)
+		e.colon()
+		if err := e.marshal(value.MapIndex(entry.key)); err != nil {
+			return err
+		}
+		if err := e.flush(flushSize); err != nil {
+			return err
+		}
+	}
+	e.depth--
+	if len(entries) > 0 {
+		e.newline()
+	}
+	e.buf = append(e.buf, '}')
+	return nil
 }
 
-func parseSlice(value reflect.Value) string {
-	sliceStr := ""
-	count := value.Len()
-	elemTy := value.Type().Elem()
+// parseObject writes an *Object with its keys in order.
+func (e *encodeState) parseObject(object *Object) error {
+	e.buf = append(e.buf, '{')
+	e.depth++
+	keys := object.Keys()
+	for i, key := range keys {
+		if i > 0 {
+			e.buf = append(e.buf, ',')
+		}
+		e.newline()
+		e.parseString(key)
+		e.colon()
+		value, _ := object.Get(key)
+		if err := e.marshal(reflect.ValueOf(value)); err != nil {
+			return err
+		}
+		if err := e.flush(flushSize); err != nil {
+			return err
+		}
+	}
+	e.depth--
+	if len(keys) > 0 {
+		e.newline()
+	}
+	e.buf = append(e.buf, '}')
+	return nil
+}
 
-	switch elemTy.Kind() {
-	default:
-		for sliceIndex := 0; sliceIndex < count; sliceIndex++ {
-			child := value.Index(sliceIndex)
+// mapKey returns the object key for a map key. String kinds are used as they
+// are, even when they implement encoding.TextMarshaler.
+func mapKey(key reflect.Value) (string, error) {
+	if key.Kind() == reflect.String {
+		return key.String(), nil
+	}
 
-			sliceStr = str.Contact(sliceStr, child, ",")
+	if marshaler, ok := key.Interface().(encoding.TextMarshaler); ok {
+		if key.Kind() == reflect.Ptr && key.IsNil() {
+			return "", nil
 		}
-		sliceStr = removeLastComma(sliceStr)
-		sliceStr = str.Contact("[", sliceStr, "]")
-	case reflect.Struct:
-		for sliceIndex := 0; sliceIndex < count; sliceIndex++ {
-			child := value.Index(sliceIndex)
+		text, err := marshaler.MarshalText()
+		return string(text), err
+	}
 
-			sliceStr = str.Contact(sliceStr, parseStruct(child.Type(), child), ",")
+	switch key.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(key.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(key.Uint(), 10), nil
+	}
+	return "", &UnsupportedTypeError{Type: key.Type()}
+}
+
+// parseStruct writes the fields of a struct resolved from their json tags,
+// leaving out empty ",omitempty" fields and fields behind nil embedded
+// pointers, followed by the entries of its ",unknown" field.
+func (e *encodeState) parseStruct(value reflect.Value) error {
+	e.buf = append(e.buf, '{')
+	e.depth++
+	first := true
+	fields := cachedFields(value.Type())
+	for _, f := range fields.list {
+		fieldValue, ok := fieldByIndex(value, f.index, false)
+		if !ok || f.omitEmpty && isEmptyValue(fieldValue) {
+			continue
 		}
-		sliceStr = removeLastComma(sliceStr)
-		sliceStr = str.Contact("[", sliceStr, "]")
-	case reflect.Ptr:
-		for sliceIndex := 0; sliceIndex < count; sliceIndex++ {
-			child := value.Index(sliceIndex)
-			fieldElem := child.Elem()
 
-			if !fieldElem.IsValid() {
-				break
-			}
+		if !first {
+			e.buf = append(e.buf, ',')
+		}
+		first = false
+
+		e.newline()
+		e.parseString(f.name)
+		e.colon()
 
-			sliceStr = str.Contact(sliceStr, fieldElem, ",")
+		var err error
+		if f.quoted {
+			err = e.parseQuoted(fieldValue)
+		} else {
+			err = e.marshal(fieldValue)
+		}
+		if err != nil {
+			return err
+		}
+		if err := e.flush(flushSize); err != nil {
+			return err
 		}
-		sliceStr = removeLastComma(sliceStr)
-		sliceStr = str.Contact("[", sliceStr, "]")
 	}
-	return sliceStr
-}
 
-func addBrace(json string) string {
-	json = str.Contact("{", json, "}")
-	return json
+	first, err := e.parseUnknown(value, fields, first)
+	if err != nil {
+		return err
+	}
+	e.depth--
+	if !first {
+		e.newline()
+	}
+	e.buf = append(e.buf, '}')
+	return nil
 }
 
-func removeLastComma(json string) string {
-	if strings.Contains(json, ",") {
-		json = json[:len(json)-1]
+// parseUnknown writes the entries of the field tagged ",unknown" as keys of
+// the struct itself, in sorted order, skipping names taken by other fields.
+// It reports whether no field has been written yet.
+func (e *encodeState) parseUnknown(value reflect.Value, fields *structFields, first bool) (bool, error) {
+	if fields.unknown == nil {
+		return first, nil
+	}
+	catchAll, ok := fieldByIndex(value, fields.unknown, false)
+	if !ok || catchAll.Len() == 0 {
+		return first, nil
 	}
-	return json
-}
 
-func parseStructFieldPtr(value reflect.Value, fieldName string) string {
-	json := ""
+	keys := catchAll.MapKeys()
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	for _, key := range keys {
+		if _, taken := fields.byName[key.String()]; taken {
+			continue
+		}
 
-	switch value.Type().Kind() {
-	case reflect.String:
-		if realValue, ok := value.Interface().(string); ok {
-			json = str.Contact(json, fieldName, ":", realValue, ",")
+		if !first {
+			e.buf = append(e.buf, ',')
 		}
-	case reflect.Int:
-		if realValue, ok := value.Interface().(int); ok {
-			json = str.Contact(json, fieldName, ":", realValue, ",")
+		first = false
+
+		e.newline()
+		e.parseString(key.String())
+		e.colon()
+		if err := e.marshal(catchAll.MapIndex(key)); err != nil {
+			return first, err
 		}
+	}
+	return first, nil
+}
+
+// parseQuoted writes the value of a field tagged ",string" as a string
+// holding its JSON encoding.
+func (e *encodeState) parseQuoted(value reflect.Value) error {
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			e.buf = append(e.buf, "null"...)
+			return nil
+		}
+		value = value.Elem()
+	}
 
-	case reflect.Int8:
-		if realValue, ok := value.Interface().(int8); ok {
-			json = str.Contact(json, fieldName, ":", realValue, ",")
+	inner := &encodeState{escapeHTML: e.escapeHTML}
+	if err := inner.marshal(value); err != nil {
+		return err
+	}
+	e.parseString(string(inner.buf))
+	return nil
+}
+
+// parseSlice writes a slice or an array. A nil slice is null and a []byte is a
+// base64 string, as in encoding/json.
+func (e *encodeState) parseSlice(value reflect.Value) error {
+	if value.Kind() == reflect.Slice {
+		if value.IsNil() {
+			e.buf = append(e.buf, "null"...)
+			return nil
 		}
-	case reflect.Int16:
-		if realValue, ok := value.Interface().(int16); ok {
-			json = str.Contact(json, fieldName, ":", realValue, ",")
+
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			e.buf = append(e.buf, '"')
+			e.buf = append(e.buf, base64.StdEncoding.EncodeToString(value.Bytes())...)
+			e.buf = append(e.buf, '"')
+			return nil
 		}
-	case reflect.Int32:
-		if realValue, ok := value.Interface().(int32); ok {
-			json = str.Contact(json, fieldName, ":", realValue, ",")
+	}
+
+	e.buf = append(e.buf, '[')
+	e.depth++
+	for i := 0; i < value.Len(); i++ {
+		if i > 0 {
+			e.buf = append(e.buf, ',')
 		}
-	case reflect.Int64:
-		if realValue, ok := value.Interface().(int64); ok {
-			json = str.Contact(json, fieldName, ":", realValue, ",")
+		e.newline()
+		if err := e.marshal(value.Index(i)); err != nil {
+			return err
 		}
-	case reflect.Bool:
-		if realValue, ok := value.Interface().(bool); ok {
-			json = str.Contact(json, fieldName, ":", realValue, ",")
+		if err := e.flush(flushSize); err != nil {
+			return err
 		}
-	case reflect.Float32:
-		if realValue, ok := value.Interface().(float32); ok {
-			json = str.Contact(json, fieldName, ":", realValue, ",")
+	}
+	e.depth--
+	if value.Len() > 0 {
+		e.newline()
+	}
+	e.buf = append(e.buf, ']')
+	return nil
+}
+
+// quote returns s as a JSON string literal, escaped byte-for-byte the way
+// encoding/json escapes it. With escapeHTML, '<', '>' and '&' are escaped as
+// well so the result is safe to embed in HTML.
+func quote(s string, escapeHTML bool) string {
+	return string(appendQuoted(nil, s, escapeHTML))
+}
+
+// appendQuoted appends s to dst as a JSON string literal, see quote.
+func appendQuoted(dst []byte, s string, escapeHTML bool) []byte {
+	const hex = "0123456789abcdef"
+
+	dst = append(dst, '"')
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			switch {
+			case c == '"' || c == '\\':
+				dst = append(dst, '\\', c)
+			case c == '\b':
+				dst = append(dst, '\\', 'b')
+			case c == '\f':
+				dst = append(dst, '\\', 'f')
+			case c == '\n':
+				dst = append(dst, '\\', 'n')
+			case c == '\r':
+				dst = append(dst, '\\', 'r')
+			case c == '\t':
+				dst = append(dst, '\\', 't')
+			case c < 0x20 || (escapeHTML && (c == '<' || c == '>' || c == '&')):
+				dst = append(dst, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xF])
+			default:
+				dst = append(dst, c)
+			}
+			i++
+			continue
 		}
-	case reflect.Float64:
-		if realValue, ok := value.Interface().(float64); ok {
-			json = str.Contact(json, fieldName, ":", realValue, ",")
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			dst = utf8.AppendRune(dst, utf8.RuneError)
+		case r == '\u2028' || r == '\u2029':
+			// Valid in JSON but not in JavaScript source.
+			dst = append(dst, '\\', 'u', '2', '0', '2', hex[r&0xF])
+		default:
+			dst = append(dst, s[i:i+size]...)
 		}
+		i += size
+	}
+	return append(dst, '"')
+}
+
+// isBare reports whether s reads back unchanged as an unquoted string in the
+// relaxed syntax: it must not look like a number, a keyword or a comment, nor
+// contain whitespace, control characters, delimiters or quotes.
+func isBare(s string) bool {
+	if s == "" || s == "true" || s == "false" || s == "null" || s[0] == '/' || s[0] == '-' ||
+		('0' <= s[0] && s[0] <= '9') || !utf8.ValidString(s) {
+		return false
 	}
 
-	return json
+	for _, char := range s {
+		if unicode.IsSpace(char) || unicode.IsControl(char) || strings.ContainsRune(`:,{}[]"\`, char) {
+			return false
+		}
+	}
+	return true
 }
@@ -2,9 +2,20 @@ package json
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/qw20012/go-json/token"
 )
 
 func TestParse(t *testing.T) {
@@ -199,3 +210,776 @@ func TestMarshal(t *testing.T) {
 		t.Fatalf("TestUnmarshal expected Type=%v, Got=%v", "1.2", json)
 	}
 }
+
+func TestParseE(t *testing.T) {
+	tests := []struct {
+		input string
+		msg   string
+	}{
+		{`{"a" 1}`, `expected ':' after key "a", got "1"`},
+		{`{"a":1 "b":2}`, `expected ',' or '}' after value of key "a", got "b"`},
+		{`[1 2]`, `expected ',' or ']' after array element, got "2"`},
+		{`{"a":`, `expected value, got end of input`},
+		{`[1,2`, `expected ',' or ']' after array element, got end of input`},
+		{`{"a":"b}`, `unterminated string`},
+		{`[1] 2`, `expected end of input, got "2"`},
+		{`}`, `expected value, got "}"`},
+	}
+
+	for i, test := range tests {
+		container, err := ParseE(test.input)
+		if container != nil {
+			t.Fatalf("On test[%d], expected nil container, Got=%v", i, container.Data())
+		}
+		syntaxErr, ok := err.(*SyntaxError)
+		if !ok {
+			t.Fatalf("On test[%d], expected *SyntaxError, Got=%T", i, err)
+		}
+		if syntaxErr.Msg != test.msg {
+			t.Fatalf("On test[%d], expected Msg=%s, Got=%s", i, test.msg, syntaxErr.Msg)
+		}
+	}
+
+	if _, err := UnmarshalE[map[string]int](`Count:1, Books:`); err == nil {
+		t.Fatalf("TestParseE expected error for truncated input")
+	}
+
+	aArray, err := UnmarshalE[[]string](`[a, b, c,]`)
+	if err != nil || len(aArray) != 3 {
+		t.Fatalf("TestParseE expected Type=%v, Got=%v (%v)", 3, len(aArray), err)
+	}
+}
+
+func TestSyntaxErrorPosition(t *testing.T) {
+	_, err := ParseE("{\n  \"name\": \"app\",\n  \"timeout\" 30\n}")
+	syntaxErr, ok := err.(*SyntaxError)
+	if !ok {
+		t.Fatalf("TestSyntaxErrorPosition expected *SyntaxError, Got=%T", err)
+	}
+	if syntaxErr.Pos.Line != 3 || syntaxErr.Pos.Column != 13 {
+		t.Fatalf("TestSyntaxErrorPosition expected Pos=3:13, Got=%v", syntaxErr.Pos)
+	}
+
+	expected := "3:13: expected ':' after key \"timeout\", got \"30\"\n  \"timeout\" 30\n            ^"
+	if err.Error() != expected {
+		t.Fatalf("TestSyntaxErrorPosition expected=%q, Got=%q", expected, err.Error())
+	}
+}
+
+func TestUnmarshalFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	content := `
+	// '{' Outer barce can be ignored.
+
+	// Quotes can be ignored when string contains no space.
+	name:value,
+
+	"key":"value",
+
+	/*
+	  Block comments
+	  Trailing comma is allowed.
+	*/
+	"another one" : true,`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := UnmarshalFile[map[string]any](path)
+	if err != nil {
+		t.Fatalf("UnmarshalFile failed: %v", err)
+	}
+	if config["key"].(string) != "value" {
+		t.Fatalf("UnmarshalFile expected Type=%s, Got=%v", "value", config["key"])
+	}
+
+	container, err := ParseFile(path)
+	if err != nil || container.Path("name").Data() != "value" {
+		t.Fatalf("ParseFile expected Type=%s, Got=%v (%v)", "value", container.Path("name").Data(), err)
+	}
+
+	out := filepath.Join(dir, "out.json")
+	if err := MarshalFile(out, map[string]any{"key": "value"}); err != nil {
+		t.Fatalf("MarshalFile failed: %v", err)
+	}
+	written, err := UnmarshalFile[map[string]string](out)
+	if err != nil || written["key"] != "value" {
+		t.Fatalf("MarshalFile expected Type=%s, Got=%v (%v)", "value", written, err)
+	}
+
+	broken := filepath.Join(dir, "broken.json")
+	if err := os.WriteFile(broken, []byte("name: value,\ncount 3"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = UnmarshalFile[map[string]any](broken)
+	if err == nil || !strings.HasPrefix(err.Error(), broken+":2:7: ") {
+		t.Fatalf("UnmarshalFile expected error at %s:2:7, Got=%v", broken, err)
+	}
+
+	type Config struct {
+		Name string
+		Port int
+	}
+	typed := filepath.Join(dir, "typed.json")
+	if err := os.WriteFile(typed, []byte("name: api,\n  port: abc\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = UnmarshalFile[Config](typed)
+	var typeErr *UnmarshalTypeError
+	if !errors.As(err, &typeErr) || typeErr.File != typed || typeErr.Pos.Line != 2 || typeErr.Pos.Column != 9 ||
+		!strings.HasPrefix(err.Error(), typed+":2:9: ") {
+		t.Fatalf("UnmarshalFile expected a type error at %s:2:9, Got=%v", typed, err)
+	}
+
+	_, err = UnmarshalFile[Config](typed, UnknownFields(RejectUnknown))
+	if err == nil || !strings.HasPrefix(err.Error(), typed+":2:9: ") {
+		t.Fatalf("UnmarshalFile expected a type error at %s:2:9, Got=%v", typed, err)
+	}
+	if err := os.WriteFile(typed, []byte("name: api,\nprot: 80\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = UnmarshalFile[Config](typed, UnknownFields(RejectUnknown))
+	if want := typed + ":2:1: unknown field prot (did you mean Port?)"; err == nil || err.Error() != want {
+		t.Fatalf("UnmarshalFile expected %s, Got=%v", want, err)
+	}
+
+	if _, err := ParseFile(filepath.Join(dir, "missing.json")); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("ParseFile expected fs.ErrNotExist, Got=%v", err)
+	}
+}
+
+func TestTypedScalars(t *testing.T) {
+	container := Parse(`count: 3, quoted: "3", on: true, text: "true", none: null, list: [1, false, x]`)
+
+	tests := []struct {
+		path     string
+		expected any
+	}{
+		{"count", int64(3)},
+		{"quoted", "3"},
+		{"on", true},
+		{"text", "true"},
+		{"none", nil},
+		{"list.0", int64(1)},
+		{"list.1", false},
+		{"list.2", "x"},
+	}
+	for i, test := range tests {
+		if got := container.Path(test.path).Data(); got != test.expected {
+			t.Fatalf("On test[%d], expected %T(%v), Got=%T(%v)", i, test.expected, test.expected, got, got)
+		}
+	}
+
+	type Config struct {
+		Count int8
+		Ratio float64
+	}
+	_, err := UnmarshalE[Config](`Count: 300`)
+	typeErr, ok := err.(*UnmarshalTypeError)
+	if !ok || typeErr.Path != "Count" || typeErr.Value != "number 300" {
+		t.Fatalf("TestTypedScalars expected *UnmarshalTypeError at Count, Got=%v", err)
+	}
+	if _, err := UnmarshalE[Config](`Count: abc`); err == nil {
+		t.Fatalf("TestTypedScalars expected error for string into int8")
+	}
+	config, err := UnmarshalE[Config](`Count: 7, Ratio: 2`)
+	if err != nil || config.Count != 7 || config.Ratio != 2 {
+		t.Fatalf("TestTypedScalars expected {7 2}, Got=%v (%v)", config, err)
+	}
+
+	// A quoted string stays a string: it goes into a bool or a number only
+	// through the string tag option.
+	for _, input := range []string{`Count: "7"`, `Ratio: "2.5"`} {
+		if _, err := UnmarshalE[Config](input); err == nil {
+			t.Fatalf("TestTypedScalars expected a type error for %s", input)
+		}
+	}
+	if _, err := UnmarshalE[struct{ B bool }](`B: "true"`); err == nil {
+		t.Fatalf("TestTypedScalars expected a type error for a quoted bool")
+	}
+
+	type Quoted struct {
+		B bool    `json:",string"`
+		N int     `json:",string"`
+		F float64 `json:",string"`
+	}
+	quoted, err := UnmarshalE[Quoted](`B: "true", N: "12", F: "1.5"`)
+	if err != nil || quoted != (Quoted{true, 12, 1.5}) {
+		t.Fatalf("TestTypedScalars expected {true 12 1.5}, Got=%v (%v)", quoted, err)
+	}
+	if _, err := UnmarshalE[Quoted](`N: "twelve"`); err == nil {
+		t.Fatalf("TestTypedScalars expected a type error for a string tag without a number")
+	}
+}
+
+func TestParseNumbers(t *testing.T) {
+	container := Parse(`a: -1.5e10, b: 42, c: 1.2.3, d: 99999999999999999999`)
+	if container.Path("a").Data() != -1.5e10 {
+		t.Fatalf("TestParseNumbers expected Type=%v, Got=%v", -1.5e10, container.Path("a").Data())
+	}
+	if container.Path("b").Data() != int64(42) {
+		t.Fatalf("TestParseNumbers expected Type=%v, Got=%v", 42, container.Path("b").Data())
+	}
+	if container.Path("c").Data() != "1.2.3" {
+		t.Fatalf("TestParseNumbers expected Type=%v, Got=%v", "1.2.3", container.Path("c").Data())
+	}
+	if container.Path("d").Data() != 1e20 {
+		t.Fatalf("TestParseNumbers expected Type=%v, Got=%v", 1e20, container.Path("d").Data())
+	}
+
+	if _, err := ParseE(`{"ratio": 1.}`); err == nil || !strings.HasPrefix(err.Error(), `1:11: invalid number "1."`) {
+		t.Fatalf("TestParseNumbers expected invalid number at 1:11, Got=%v", err)
+	}
+}
+
+func TestQuote(t *testing.T) {
+	tests := []string{
+		"plain",
+		`say "hi"`,
+		"tab\tnew\nline\rback\\slash",
+		"\b\f\x00\x1f",
+		"<a href=\"x\">&</a>",
+		"café 😀",
+		"  ",
+		"bad \xff utf8",
+	}
+
+	for i, test := range tests {
+		expected, _ := json.Marshal(test)
+		if got := quote(test, true); got != string(expected) {
+			t.Fatalf("On test[%d], expected %s, Got=%s", i, expected, got)
+		}
+
+		if !utf8.ValidString(test) {
+			continue
+		}
+		if got := Parse("key: " + quote(test, true)).Path("key").Data(); got != test {
+			t.Fatalf("On test[%d], expected round trip %q, Got=%q", i, test, got)
+		}
+	}
+
+	if got := quote("<&>", false); got != `"<&>"` {
+		t.Fatalf("TestQuote expected %s, Got=%s", `"<&>"`, got)
+	}
+}
+
+func TestNull(t *testing.T) {
+	type Book struct {
+		Name  *string
+		Pages int
+		Tags  []string
+		Extra any
+	}
+
+	book := Unmarshal[Book](`Name: null, Pages: null, Tags: null, Extra: null`)
+	if book.Name != nil || book.Pages != 0 || book.Tags != nil || book.Extra != nil {
+		t.Fatalf("TestNull expected zero Book, Got=%v", book)
+	}
+
+	pages := Unmarshal[map[string]*int](`a: 1, b: null`)
+	if v, ok := pages["b"]; !ok || v != nil || *pages["a"] != 1 {
+		t.Fatalf("TestNull expected map[a:1 b:nil], Got=%v", pages)
+	}
+
+	ptrs := Unmarshal[[]*int](`[1, null, 3]`)
+	if len(ptrs) != 3 || ptrs[1] != nil || *ptrs[2] != 3 {
+		t.Fatalf("TestNull expected [1 nil 3], Got=%v", ptrs)
+	}
+
+	if json := Marshal(Book{Pages: 1}); !strings.Contains(json, `"Name":null`) {
+		t.Fatalf("TestNull expected Type=%s, Got=%v", `"Name":null`, json)
+	}
+	var nilBook *Book
+	if json := Marshal(nilBook); json != "null" {
+		t.Fatalf("TestNull expected Type=%s, Got=%v", "null", json)
+	}
+	if json := Marshal(map[string]any{"a": nil}); json != `{"a":null}` {
+		t.Fatalf("TestNull expected Type=%s, Got=%v", `{"a":null}`, json)
+	}
+}
+
+func TestMarshalStrict(t *testing.T) {
+	type Book struct {
+		Name   string
+		Pages  *int `json:"pages"`
+		Ratio  float64
+		Small  float32
+		Count  uint8
+		Tags   []string
+		Extra  any
+		hidden int
+	}
+	pages := 10
+	book := Book{Name: `say "hi", <me>`, Pages: &pages, Ratio: 1e-7, Small: 1.2, Count: 7,
+		Tags: []string{"a b", "c"}, Extra: map[string]any{"list": []any{1, "x", nil, true}}}
+
+	got := Marshal(book)
+	expected, _ := json.Marshal(book)
+	if got != string(expected) {
+		t.Fatalf("TestMarshalStrict expected %s, Got=%s", expected, got)
+	}
+	if !json.Valid([]byte(Marshal(map[string]string{"key with space": "value, with comma"}))) {
+		t.Fatalf("TestMarshalStrict expected valid JSON")
+	}
+
+	if _, err := MarshalE(math.NaN()); err == nil {
+		t.Fatalf("TestMarshalStrict expected error for NaN")
+	}
+	if _, err := MarshalE(make(chan int)); err == nil {
+		t.Fatalf("TestMarshalStrict expected error for chan")
+	}
+}
+
+func TestMarshalHuman(t *testing.T) {
+	type Book struct {
+		Name  string
+		Title string
+		Code  string
+		Flag  string
+		Empty string
+		Path  string
+		Tags  []string
+	}
+	book := Book{Name: "red", Title: "two words", Code: "123", Flag: "true", Path: "//x", Tags: []string{"a", "b,c"}}
+
+	got := Marshal(book, Human())
+	expected := `{Name:red,Title:"two words",Code:"123",Flag:"true",Empty:"",Path:"//x",Tags:[a,"b,c"]}`
+	if got != expected {
+		t.Fatalf("TestMarshalHuman expected %s, Got=%s", expected, got)
+	}
+
+	if back := Unmarshal[Book](got); fmt.Sprint(back) != fmt.Sprint(book) {
+		t.Fatalf("TestMarshalHuman expected round trip %v, Got=%v", book, back)
+	}
+}
+
+type textKey struct {
+	a, b string
+}
+
+func (k textKey) MarshalText() ([]byte, error) {
+	return []byte(k.a + "-" + k.b), nil
+}
+
+func TestMarshalKinds(t *testing.T) {
+	type Kinds struct {
+		IntKeys   map[int]string
+		UintKeys  map[uint16]bool
+		TextKeys  map[textKey]int
+		Bytes     []byte
+		When      time.Time
+		NilSlice  []int
+		NilMap    map[string]int
+		Array     [2]int8
+		Big       uint64
+		Nested    []any
+		Structs   map[string][]struct{ A int }
+		Interface fmt.Stringer
+		PtrPtr    **string
+	}
+	name := "x"
+	namePtr := &name
+	kinds := Kinds{
+		IntKeys:  map[int]string{-1: "minus"},
+		UintKeys: map[uint16]bool{7: true},
+		TextKeys: map[textKey]int{{"a", "b"}: 1},
+		Bytes:    []byte("hello, world"),
+		When:     time.Date(2022, 6, 27, 12, 14, 53, 5, time.UTC),
+		Array:    [2]int8{-1, 1},
+		Big:      math.MaxUint64,
+		Nested:   []any{[]any{1, "a"}, map[string]any{"b": []any{}}, nil},
+		Structs:  map[string][]struct{ A int }{"s": {{A: 1}, {A: 2}}},
+		PtrPtr:   &namePtr,
+	}
+
+	got, err := MarshalE(kinds)
+	expected, _ := json.Marshal(kinds)
+	if err != nil || got != string(expected) {
+		t.Fatalf("TestMarshalKinds expected %s, Got=%s (%v)", expected, got, err)
+	}
+
+	if _, err := MarshalE(map[float64]int{1: 1}); err == nil {
+		t.Fatalf("TestMarshalKinds expected error for float keys")
+	}
+	if _, err := MarshalE(struct{ F func() }{}); err == nil {
+		t.Fatalf("TestMarshalKinds expected error for func field")
+	}
+}
+
+func (k *textKey) UnmarshalText(text []byte) error {
+	a, b, ok := strings.Cut(string(text), "-")
+	if !ok {
+		return errors.New("missing '-' in key")
+	}
+	k.a, k.b = a, b
+	return nil
+}
+
+func TestUnmarshalKinds(t *testing.T) {
+	type Item struct {
+		ID   uint16
+		Tags map[string][]int
+	}
+	type Kinds struct {
+		IntKeys   map[int8]string
+		UintKeys  map[uint]bool
+		TextKeys  map[textKey]int
+		Items     map[string]Item
+		ItemPtrs  []*Item
+		Maps      []map[string]float32
+		Grid      [2][3]int
+		Bytes     []byte
+		When      time.Time
+		Big       uint64
+		Nested    any
+		PtrPtr    **string
+		NamedList []textKey
+	}
+	name := "x"
+	namePtr := &name
+	kinds := Kinds{
+		IntKeys:  map[int8]string{-1: "minus", 2: "two"},
+		UintKeys: map[uint]bool{7: true},
+		TextKeys: map[textKey]int{{"a", "b"}: 1},
+		Items:    map[string]Item{"first": {ID: 1, Tags: map[string][]int{"t": {1, 2}}}},
+		ItemPtrs: []*Item{{ID: 2}, nil},
+		Maps:     []map[string]float32{{"a": 1.5}, {}},
+		Grid:     [2][3]int{{1, 2, 3}, {4, 5, 6}},
+		Bytes:    []byte("hello, world"),
+		When:     time.Date(2022, 6, 27, 12, 14, 53, 5, time.UTC),
+		Big:      math.MaxUint64,
+		Nested:   []any{[]any{int64(1), "a"}, map[string]any{"b": []any{}}},
+		PtrPtr:   &namePtr,
+	}
+
+	got, err := UnmarshalE[Kinds](Marshal(kinds))
+	if err != nil || !reflect.DeepEqual(got, kinds) {
+		t.Fatalf("TestUnmarshalKinds expected %+v, Got=%+v (%v)", kinds, got, err)
+	}
+
+	grid := Unmarshal[[2]int](`[1, 2, 3]`)
+	if grid != [2]int{1, 2} {
+		t.Fatalf("TestUnmarshalKinds expected [1 2], Got=%v", grid)
+	}
+
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{`IntKeys: {"300": x}`, `1:11: cannot unmarshal object key "300" into Go value of type int8 at IntKeys.300`},
+		{`TextKeys: {ab: 1}`, `missing '-' in key`},
+		{`Big: -1`, `1:6: cannot unmarshal number -1 into Go value of type uint64 at Big`},
+		{`Grid: [[1], [2, x]]`, `1:17: cannot unmarshal string "x" into Go value of type int at Grid.1.1`},
+		{`Bytes: "!!"`, `1:8: cannot unmarshal string "!!" into Go value of type []uint8 at Bytes`},
+		{`Items: {a: {ID: 1.5}}`, `1:17: cannot unmarshal number 1.5 into Go value of type uint16 at Items.a.ID`},
+	}
+	for i, test := range tests {
+		if _, err := UnmarshalE[Kinds](test.input); err == nil || err.Error() != test.err {
+			t.Fatalf("On test[%d], expected %s, Got=%v", i, test.err, err)
+		}
+	}
+
+	var item any = &Item{ID: 5}
+	if err := (&decodeState{}).buildValue(reflect.ValueOf(&item).Elem(), map[string]any{"Tags": nil}); err != nil ||
+		item.(*Item).ID != 5 {
+		t.Fatalf("TestUnmarshalKinds expected decoding into the held pointer, Got=%v (%v)", item, err)
+	}
+}
+
+func TestStructTags(t *testing.T) {
+	type Base struct {
+		ID   int `json:"id"`
+		Note string
+	}
+	type Extra struct {
+		Level int `json:"level,omitempty"`
+	}
+	type Book struct {
+		*Base
+		Title   string            `json:"title"`
+		Pages   int               `json:"pages,omitempty"`
+		Secret  string            `json:"-"`
+		Dash    string            `json:"-,"`
+		Count   int64             `json:"count,string"`
+		Name    string            `json:"name,string"`
+		Ratio   *float64          `json:"ratio,string,omitempty"`
+		Labels  map[string]string `json:",omitempty"`
+		Extra   Extra             `json:",inline"`
+		private int
+	}
+
+	tests := []struct {
+		book Book
+		want string
+	}{
+		{Book{Title: "Go", Secret: "s", Count: 3, Name: "x"},
+			`{"title":"Go","-":"","count":"3","name":"\"x\""}`},
+		{Book{Base: &Base{ID: 7}, Pages: 120, Extra: Extra{Level: 2}},
+			`{"id":7,"Note":"","title":"","pages":120,"-":"","count":"0","name":"\"\"","level":2}`},
+	}
+	for i, test := range tests {
+		got := Marshal(test.book)
+		if got != test.want {
+			t.Fatalf("On test[%d], expected %s, Got=%s", i, test.want, got)
+		}
+
+		if !json.Valid([]byte(got)) {
+			t.Fatalf("On test[%d], expected valid JSON, Got=%s", i, got)
+		}
+
+		expected := test.book
+		expected.Secret = ""
+		back, err := UnmarshalE[Book](got)
+		if err != nil || !reflect.DeepEqual(back, expected) {
+			t.Fatalf("On test[%d], expected %+v, Got=%+v (%v)", i, expected, back, err)
+		}
+	}
+
+	book, err := UnmarshalE[Book](`{TITLE: Go, Id: 3, PAGES: 10, Secret: s, Level: 4, count: 12, name: "\"n\""}`)
+	want := Book{Base: &Base{ID: 3}, Title: "Go", Pages: 10, Extra: Extra{Level: 4}, Count: 12, Name: "n"}
+	if err != nil || !reflect.DeepEqual(book, want) {
+		t.Fatalf("TestStructTags expected %+v, Got=%+v (%v)", want, book, err)
+	}
+
+	if _, err := UnmarshalE[Book](`name: plain`); err == nil ||
+		err.Error() != `1:7: cannot unmarshal string "plain" into Go value of type string at name` {
+		t.Fatalf("TestStructTags expected a type error for name, Got=%v", err)
+	}
+
+	type Left struct{ Value int }
+	type Right struct{ Value int }
+	type Both struct {
+		Left
+		Right
+	}
+	if got := Marshal(Both{Left{1}, Right{2}}); got != `{}` {
+		t.Fatalf("TestStructTags expected ambiguous fields to be dropped, Got=%s", got)
+	}
+}
+
+func TestUnknownFields(t *testing.T) {
+	type Item struct {
+		Name  string `json:"name,required"`
+		Count int
+	}
+	type Config struct {
+		Title string         `json:"title,required"`
+		Items []Item         `json:"items"`
+		Extra map[string]any `json:",unknown"`
+	}
+
+	input := `title: t, Cuont: 3, items: [{name: a, Cuont: 1}, {nmae: b}], zzz: [1]`
+
+	config, err := UnmarshalE[Config](input)
+	if err == nil || err.Error() != "missing required field items.1.name" || config.Extra != nil || len(config.Items) != 2 {
+		t.Fatalf("TestUnknownFields expected unknown keys to be ignored, Got=%+v (%v)", config, err)
+	}
+
+	// Missing required fields are reported whatever the policy.
+	_, err = UnmarshalE[Config](`items: [{Count: 1}]`)
+	want := "missing required field items.0.name; missing required field title"
+	if fieldErr, ok := err.(*FieldError); !ok || err.Error() != want || len(fieldErr.Missing) != 2 {
+		t.Fatalf("TestUnknownFields expected %s, Got=%v", want, err)
+	}
+
+	config, err = UnmarshalE[Config](input, UnknownFields(CollectUnknown))
+	extra := map[string]any{"Cuont": int64(3), "zzz": []any{int64(1)}}
+	if err == nil || !reflect.DeepEqual(config.Extra, extra) {
+		t.Fatalf("TestUnknownFields expected %v, Got=%v (%v)", extra, config.Extra, err)
+	}
+	if got := Marshal(Config{Title: "t", Extra: map[string]any{"b": 2, "a": 1, "title": "x"}}); got !=
+		`{"title":"t","items":null,"a":1,"b":2}` {
+		t.Fatalf("TestUnknownFields expected collected keys to be written back, Got=%s", got)
+	}
+
+	_, err = UnmarshalE[Config](input, UnknownFields(RejectUnknown))
+	want = "1:11: unknown field Cuont; 1:39: unknown field items.0.Cuont (did you mean Count?); " +
+		"1:51: unknown field items.1.nmae (did you mean name?); 1:62: unknown field zzz; " +
+		"missing required field items.1.name"
+	if err == nil || err.Error() != want {
+		t.Fatalf("TestUnknownFields expected %s, Got=%v", want, err)
+	}
+
+	if _, err := UnmarshalE[Item](`{Count: 1, Cuont: 2}`, UnknownFields(RejectUnknown)); err == nil ||
+		err.(*FieldError).Unknown[0] != (UnknownField{Path: "Cuont", Suggestion: "Count", Pos: token.Pos{Offset: 11, Line: 1, Column: 12}}) {
+		t.Fatalf("TestUnknownFields expected a suggestion for Cuont, Got=%v", err)
+	}
+}
+
+type duration time.Duration
+
+func (d duration) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(time.Duration(d).String())), nil
+}
+
+func (d *duration) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(text)
+	*d = duration(parsed)
+	return err
+}
+
+type byteSize int64
+
+func (b byteSize) MarshalText() ([]byte, error) {
+	return []byte(strconv.FormatInt(int64(b)/1024, 10) + "KB"), nil
+}
+
+func (b *byteSize) UnmarshalText(text []byte) error {
+	kb, err := strconv.ParseInt(strings.TrimSuffix(string(text), "KB"), 10, 64)
+	*b = byteSize(kb * 1024)
+	return err
+}
+
+type level int
+
+func (l level) MarshalJSON() ([]byte, error) {
+	if l < 0 {
+		return nil, errors.New("negative level")
+	}
+	return []byte(" " + strconv.Itoa(int(l)) + "\n"), nil
+}
+
+// spaced writes its JSON with whitespace inside and its keys out of order.
+type spaced struct{}
+
+func (spaced) MarshalJSON() ([]byte, error) {
+	return []byte("{\n  \"b\" :   [1,\n 2], \"a\": \"x y\" }"), nil
+}
+
+func TestMarshalerWhitespace(t *testing.T) {
+	source := map[string]any{"x": spaced{}}
+	tests := []struct {
+		opts []EncodeOption
+		want string
+	}{
+		{nil, `{"x":{"b":[1,2],"a":"x y"}}`},
+		{[]EncodeOption{Indent("", "  ")}, "{\n  \"x\": {\n    \"b\": [\n      1,\n      2\n    ],\n    \"a\": \"x y\"\n  }\n}"},
+		{[]EncodeOption{Human()}, `{x:{b:[1,2],a:"x y"}}`},
+	}
+	for i, test := range tests {
+		if got, err := MarshalE(source, test.opts...); err != nil || got != test.want {
+			t.Fatalf("On test[%d], expected %q, Got=%q (%v)", i, test.want, got, err)
+		}
+	}
+}
+
+func TestMarshaler(t *testing.T) {
+	type Server struct {
+		Timeout  duration
+		Retry    *duration
+		Sizes    []byteSize
+		Limits   map[string]duration
+		Level    level
+		Optional *byteSize
+	}
+
+	retry := duration(time.Second)
+	server := Server{
+		Timeout: duration(30 * time.Second),
+		Retry:   &retry,
+		Sizes:   []byteSize{1024, 2048},
+		Limits:  map[string]duration{"read": duration(time.Minute)},
+		Level:   3,
+	}
+
+	want := `{"Timeout":"30s","Retry":"1s","Sizes":["1KB","2KB"],"Limits":{"read":"1m0s"},"Level":3,"Optional":null}`
+	if got := Marshal(server); got != want {
+		t.Fatalf("TestMarshaler expected %s, Got=%s", want, got)
+	}
+
+	for _, input := range []string{want, Marshal(server, Human()), `Timeout: 30s, Retry: 1s, Sizes: [1KB, 2KB], Limits: {read: 1m0s}, Level: 3`} {
+		got, err := UnmarshalE[Server](input)
+		if err != nil || !reflect.DeepEqual(got, server) {
+			t.Fatalf("TestMarshaler expected %+v, Got=%+v (%v)", server, got, err)
+		}
+	}
+
+	if _, err := UnmarshalE[Server](`Limits: {read: soon}`); err == nil ||
+		err.Error() != `Limits.read: time: invalid duration "soon"` {
+		t.Fatalf("TestMarshaler expected the error of UnmarshalJSON, Got=%v", err)
+	}
+	if _, err := UnmarshalE[Server](`Sizes: [{}]`); err == nil ||
+		err.Error() != "1:9: cannot unmarshal object into Go value of type json.byteSize at Sizes.0" {
+		t.Fatalf("TestMarshaler expected a type error, Got=%v", err)
+	}
+
+	var marshalerErr *MarshalerError
+	if _, err := MarshalE(Server{Level: -1}); !errors.As(err, &marshalerErr) ||
+		err.Error() != "error calling MarshalJSON for type json.level: negative level" {
+		t.Fatalf("TestMarshaler expected a *MarshalerError, Got=%v", err)
+	}
+}
+
+func TestMarshalSortedKeys(t *testing.T) {
+	source := map[string]any{"b": 1, "a": map[int]string{10: "x", 9: "y"}, "c": nil}
+	want := `{"a":{"10":"x","9":"y"},"b":1,"c":null}`
+	for i := 0; i < 10; i++ {
+		if got := Marshal(source); got != want {
+			t.Fatalf("Marshal() = %s, want %s", got, want)
+		}
+	}
+
+	object := NewObject()
+	object.Set("b", 1)
+	object.Set("a", []any{NewObject()})
+	object.Set("b", 2)
+	want = "{\n  \"b\": 2,\n  \"a\": [\n    {}\n  ]\n}"
+	if got := Marshal(object, Indent("", "  ")); got != want {
+		t.Errorf("Marshal(*Object) = %q, want %q", got, want)
+	}
+	if got := Marshal(struct{ O *Object }{}); got != `{"O":null}` {
+		t.Errorf("Marshal(nil *Object) = %s", got)
+	}
+}
+
+func TestDuplicateKeys(t *testing.T) {
+	input := "{\n  a: 1,\n  b: {c: [1], c: 2, c: 3},\n  a: 2,\n}"
+
+	tests := []struct {
+		opts []ParseOption
+		want string
+	}{
+		{nil, `{"a":2,"b":{"c":3}}`},
+		{[]ParseOption{DuplicateKeys(KeepLast)}, `{"a":2,"b":{"c":3}}`},
+		{[]ParseOption{DuplicateKeys(KeepFirst)}, `{"a":1,"b":{"c":[1]}}`},
+		{[]ParseOption{DuplicateKeys(CollectDuplicates)}, `{"a":[1,2],"b":{"c":[[1],2,3]}}`},
+		{[]ParseOption{DuplicateKeys(CollectDuplicates), KeepOrder()}, `{"a":[1,2],"b":{"c":[[1],2,3]}}`},
+	}
+	for _, test := range tests {
+		c, err := ParseE(input, test.opts...)
+		if err != nil {
+			t.Fatalf("ParseE(%d options): %v", len(test.opts), err)
+		}
+		if got := c.String(); got != test.want {
+			t.Errorf("ParseE(%d options) = %s, want %s", len(test.opts), got, test.want)
+		}
+	}
+
+	_, err := ParseE(input, DuplicateKeys(RejectDuplicates))
+	syntaxErr, ok := err.(*SyntaxError)
+	if !ok {
+		t.Fatalf("RejectDuplicates: got %v, want a *SyntaxError", err)
+	}
+	if want := `duplicate key "c", first at 3:7`; syntaxErr.Msg != want || syntaxErr.Pos.String() != "3:15" {
+		t.Errorf("RejectDuplicates: got %q at %s, want %q at 3:15", syntaxErr.Msg, syntaxErr.Pos, want)
+	}
+
+	var warnings []string
+	c, err := ParseE(input, WarnDuplicateKeys(func(d DuplicateKey) {
+		warnings = append(warnings, fmt.Sprintf("%s %s %s", d.Key, d.First, d.Second))
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "[c 3:7 3:15 c 3:7 3:21 a 2:3 4:3]"; fmt.Sprint(warnings) != want {
+		t.Errorf("WarnDuplicateKeys: got %v, want %s", warnings, want)
+	}
+	if got := c.String(); got != `{"a":2,"b":{"c":3}}` {
+		t.Errorf("WarnDuplicateKeys: got %s", got)
+	}
+}
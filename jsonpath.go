@@ -0,0 +1,1168 @@
+package json
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// QueryResult is a value selected by Query, with its location.
+type QueryResult struct {
+	Path      string   // normalized path, such as $['store']['book'][0]
+	Hierarchy []string // the same location for Search and Set
+	Value     *Container
+}
+
+// Query selects values with a JSONPath expression as defined by RFC 9535:
+// child and descendant segments (.name, ['name'], ..name, ..*), wildcards on
+// objects and arrays, indexes, slices such as [1:5:2], unions such as
+// [0,'a'], and filters such as [?@.price < 10] with the functions length,
+// count, match, search and value. The members of a map are visited sorted by
+// key, those of an *Object in order. A malformed expression is reported as an
+// error wrapping ErrInvalidQuery.
+func (g *Container) Query(expr string) ([]QueryResult, error) {
+	p := &queryParser{src: expr}
+	query, err := p.parseQuery('$')
+	if err == nil && p.pos < len(p.src) {
+		err = p.errorf("unexpected %q", p.src[p.pos:])
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	root := g.Data()
+	nodes := query.eval(root, root)
+	results := make([]QueryResult, len(nodes))
+	for i, node := range nodes {
+		hierarchy := make([]string, len(node.location))
+		for j, step := range node.location {
+			hierarchy[j] = fmt.Sprint(step)
+		}
+		results[i] = QueryResult{Path: node.path(), Hierarchy: hierarchy, Value: g.child(node.value, hierarchy...)}
+	}
+	return results, nil
+}
+
+// queryNode is a value reached by a query, with the member names (strings)
+// and array indexes (ints) leading to it.
+type queryNode struct {
+	value    any
+	location []any
+}
+
+func (n queryNode) child(step any, value any) queryNode {
+	location := make([]any, len(n.location)+1)
+	copy(location, n.location)
+	location[len(n.location)] = step
+	return queryNode{value: value, location: location}
+}
+
+// path returns the normalized path of the node.
+func (n queryNode) path() string {
+	var b strings.Builder
+	b.WriteByte('$')
+	for _, step := range n.location {
+		if index, ok := step.(int); ok {
+			fmt.Fprintf(&b, "[%d]", index)
+			continue
+		}
+		b.WriteString("['")
+		for _, char := range step.(string) {
+			switch char {
+			case '\b':
+				b.WriteString(`\b`)
+			case '\f':
+				b.WriteString(`\f`)
+			case '\n':
+				b.WriteString(`\n`)
+			case '\r':
+				b.WriteString(`\r`)
+			case '\t':
+				b.WriteString(`\t`)
+			case '\'':
+				b.WriteString(`\'`)
+			case '\\':
+				b.WriteString(`\\`)
+			default:
+				if char < 0x20 {
+					fmt.Fprintf(&b, `\u%04x`, char)
+				} else {
+					b.WriteRune(char)
+				}
+			}
+		}
+		b.WriteString("']")
+	}
+	return b.String()
+}
+
+// children returns the members of an object or the elements of an array.
+func children(n queryNode) []queryNode {
+	switch t := n.value.(type) {
+	case []any:
+		nodes := make([]queryNode, len(t))
+		for i, value := range t {
+			nodes[i] = n.child(i, value)
+		}
+		return nodes
+	case map[string]any, *Object:
+		keys, _ := objectKeys(t)
+		nodes := make([]queryNode, len(keys))
+		for i, key := range keys {
+			value, _ := objectGet(t, key)
+			nodes[i] = n.child(key, value)
+		}
+		return nodes
+	}
+	return nil
+}
+
+// descendants returns n followed by all values inside it, parents before
+// their children.
+func descendants(n queryNode, nodes []queryNode) []queryNode {
+	nodes = append(nodes, n)
+	for _, child := range children(n) {
+		nodes = descendants(child, nodes)
+	}
+	return nodes
+}
+
+// pathQuery is a query from the root ($) or from the current node (@).
+type pathQuery struct {
+	relative bool
+	segments []querySegment
+}
+
+type querySegment struct {
+	descendant bool
+	selectors  []selector
+}
+
+// singular reports whether the query selects at most one node.
+func (q *pathQuery) singular() bool {
+	for _, seg := range q.segments {
+		if seg.descendant || len(seg.selectors) != 1 {
+			return false
+		}
+		if kind := seg.selectors[0].kind; kind != nameSelector && kind != indexSelector {
+			return false
+		}
+	}
+	return true
+}
+
+func (q *pathQuery) eval(root, current any) []queryNode {
+	start := root
+	if q.relative {
+		start = current
+	}
+
+	nodes := []queryNode{{value: start}}
+	for _, seg := range q.segments {
+		var next []queryNode
+		for _, node := range nodes {
+			targets := []queryNode{node}
+			if seg.descendant {
+				targets = descendants(node, nil)
+			}
+			for _, target := range targets {
+				for _, sel := range seg.selectors {
+					next = sel.apply(target, root, next)
+				}
+			}
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+type selectorKind int
+
+const (
+	nameSelector selectorKind = iota
+	wildcardSelector
+	indexSelector
+	sliceSelector
+	filterSelector
+)
+
+type selector struct {
+	kind             selectorKind
+	name             string
+	index            int
+	start, end, step int
+	hasStart, hasEnd bool
+	filter           logicalExpr
+}
+
+// apply appends the nodes selected from n to nodes.
+func (s *selector) apply(n queryNode, root any, nodes []queryNode) []queryNode {
+	switch s.kind {
+	case nameSelector:
+		if value, ok := objectGet(n.value, s.name); ok {
+			nodes = append(nodes, n.child(s.name, value))
+		}
+	case wildcardSelector:
+		nodes = append(nodes, children(n)...)
+	case indexSelector:
+		if array, ok := n.value.([]any); ok {
+			index := s.index
+			if index < 0 {
+				index += len(array)
+			}
+			if index >= 0 && index < len(array) {
+				nodes = append(nodes, n.child(index, array[index]))
+			}
+		}
+	case sliceSelector:
+		if array, ok := n.value.([]any); ok {
+			for _, index := range s.indexes(len(array)) {
+				nodes = append(nodes, n.child(index, array[index]))
+			}
+		}
+	case filterSelector:
+		for _, child := range children(n) {
+			if s.filter.test(root, child.value) {
+				nodes = append(nodes, child)
+			}
+		}
+	}
+	return nodes
+}
+
+// indexes returns the indexes a slice selects from an array of length n, as
+// RFC 9535 section 2.3.4.2 defines.
+func (s *selector) indexes(n int) []int {
+	if s.step == 0 {
+		return nil
+	}
+
+	normalize := func(i int) int {
+		if i < 0 {
+			return n + i
+		}
+		return i
+	}
+	clamp := func(i, low, high int) int {
+		if i < low {
+			return low
+		}
+		if i > high {
+			return high
+		}
+		return i
+	}
+
+	var indexes []int
+	if s.step > 0 {
+		start, end := 0, n
+		if s.hasStart {
+			start = clamp(normalize(s.start), 0, n)
+		}
+		if s.hasEnd {
+			end = clamp(normalize(s.end), 0, n)
+		}
+		for i := start; i < end; i += s.step {
+			indexes = append(indexes, i)
+		}
+		return indexes
+	}
+
+	start, end := n-1, -1
+	if s.hasStart {
+		start = clamp(normalize(s.start), -1, n-1)
+	}
+	if s.hasEnd {
+		end = clamp(normalize(s.end), -1, n-1)
+	}
+	for i := start; i > end; i += s.step {
+		indexes = append(indexes, i)
+	}
+	return indexes
+}
+
+// logicalExpr is a filter expression.
+type logicalExpr interface {
+	test(root, current any) bool
+}
+
+type orExpr []logicalExpr
+
+func (e orExpr) test(root, current any) bool {
+	for _, operand := range e {
+		if operand.test(root, current) {
+			return true
+		}
+	}
+	return false
+}
+
+type andExpr []logicalExpr
+
+func (e andExpr) test(root, current any) bool {
+	for _, operand := range e {
+		if !operand.test(root, current) {
+			return false
+		}
+	}
+	return true
+}
+
+type notExpr struct {
+	operand logicalExpr
+}
+
+func (e notExpr) test(root, current any) bool {
+	return !e.operand.test(root, current)
+}
+
+// existExpr tests whether a query selects any node.
+type existExpr struct {
+	query *pathQuery
+}
+
+func (e existExpr) test(root, current any) bool {
+	return len(e.query.eval(root, current)) > 0
+}
+
+type compareExpr struct {
+	op          string
+	left, right operand
+}
+
+func (e compareExpr) test(root, current any) bool {
+	left, right := e.left.eval(root, current), e.right.eval(root, current)
+	switch e.op {
+	case "==":
+		return equalOperands(left, right)
+	case "!=":
+		return !equalOperands(left, right)
+	case "<":
+		return lessOperands(left, right)
+	case "<=":
+		return lessOperands(left, right) || equalOperands(left, right)
+	case ">":
+		return lessOperands(right, left)
+	}
+	return lessOperands(right, left) || equalOperands(left, right)
+}
+
+// functionExpr tests the result of match or search.
+type functionExpr struct {
+	call *functionCall
+}
+
+func (e functionExpr) test(root, current any) bool {
+	return e.call.eval(root, current).logical
+}
+
+// operandType is the declared type of a filter operand, RFC 9535 section
+// 2.4.1.
+type operandType int
+
+const (
+	valueType operandType = iota
+	logicalType
+	nodesType
+)
+
+// operand is a literal, a query or a function call in a filter.
+type operand interface {
+	eval(root, current any) result
+	kind() operandType
+}
+
+// result is the value of an operand: a value, nothing (for an empty query or
+// a function without a result), a logical value or a list of nodes.
+type result struct {
+	value   any
+	nothing bool
+	logical bool
+	nodes   []queryNode
+}
+
+type literal struct {
+	value any
+}
+
+func (l literal) eval(root, current any) result { return result{value: l.value} }
+func (l literal) kind() operandType             { return valueType }
+
+type queryOperand struct {
+	query *pathQuery
+}
+
+func (q queryOperand) eval(root, current any) result {
+	nodes := q.query.eval(root, current)
+	r := result{nodes: nodes, nothing: len(nodes) != 1, logical: len(nodes) > 0}
+	if len(nodes) == 1 {
+		r.value = nodes[0].value
+	}
+	return r
+}
+
+func (q queryOperand) kind() operandType { return nodesType }
+
+type functionCall struct {
+	name string
+	args []operand
+}
+
+// logicalOperand passes a logical expression as a function argument.
+type logicalOperand struct {
+	expr logicalExpr
+}
+
+func (l logicalOperand) eval(root, current any) result {
+	return result{logical: l.expr.test(root, current)}
+}
+
+func (l logicalOperand) kind() operandType { return logicalType }
+
+var queryFunctions = map[string]struct {
+	params []operandType
+	result operandType
+}{
+	"length": {[]operandType{valueType}, valueType},
+	"count":  {[]operandType{nodesType}, valueType},
+	"match":  {[]operandType{valueType, valueType}, logicalType},
+	"search": {[]operandType{valueType, valueType}, logicalType},
+	"value":  {[]operandType{nodesType}, valueType},
+}
+
+func (f *functionCall) kind() operandType {
+	return queryFunctions[f.name].result
+}
+
+func (f *functionCall) eval(root, current any) result {
+	args := make([]result, len(f.args))
+	for i, arg := range f.args {
+		args[i] = arg.eval(root, current)
+	}
+
+	switch f.name {
+	case "length":
+		if args[0].nothing {
+			return result{nothing: true}
+		}
+		switch t := args[0].value.(type) {
+		case string:
+			return result{value: int64(utf8.RuneCountInString(t))}
+		case []any:
+			return result{value: int64(len(t))}
+		case map[string]any:
+			return result{value: int64(len(t))}
+		case *Object:
+			return result{value: int64(t.Len())}
+		}
+		return result{nothing: true}
+	case "count":
+		return result{value: int64(len(args[0].nodes))}
+	case "value":
+		if len(args[0].nodes) == 1 {
+			return result{value: args[0].nodes[0].value}
+		}
+		return result{nothing: true}
+	}
+
+	// match and search
+	text, ok := args[0].value.(string)
+	pattern, ok2 := args[1].value.(string)
+	if !ok || !ok2 || args[0].nothing || args[1].nothing {
+		return result{}
+	}
+	if f.name == "match" {
+		pattern = "^(?:" + pattern + ")$"
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return result{}
+	}
+	return result{logical: re.MatchString(text)}
+}
+
+func equalOperands(a, b result) bool {
+	if a.nothing || b.nothing {
+		return a.nothing && b.nothing
+	}
+	return equalValues(a.value, b.value)
+}
+
+func lessOperands(a, b result) bool {
+	if a.nothing || b.nothing {
+		return false
+	}
+	if c, ok := compareNumbers(a.value, b.value); ok {
+		return c < 0
+	}
+	s, ok := a.value.(string)
+	t, ok2 := b.value.(string)
+	return ok && ok2 && s < t
+}
+
+// equalValues compares two values as JSON: numbers by value, arrays element
+// by element and objects member by member.
+func equalValues(a, b any) bool {
+	if c, ok := compareNumbers(a, b); ok {
+		return c == 0
+	}
+
+	if array, ok := a.([]any); ok {
+		other, ok := b.([]any)
+		if !ok || len(array) != len(other) {
+			return false
+		}
+		for i := range array {
+			if !equalValues(array[i], other[i]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if keys, ok := objectKeys(a); ok {
+		otherKeys, ok := objectKeys(b)
+		if !ok || len(keys) != len(otherKeys) {
+			return false
+		}
+		for _, key := range keys {
+			value, _ := objectGet(a, key)
+			other, ok := objectGet(b, key)
+			if !ok || !equalValues(value, other) {
+				return false
+			}
+		}
+		return true
+	}
+
+	switch a.(type) {
+	case nil, bool, string:
+		return a == b
+	}
+	return false
+}
+
+// compareNumbers compares a and b when both are numbers, exactly when both
+// are integers.
+func compareNumbers(a, b any) (int, bool) {
+	x, y := reflect.ValueOf(a), reflect.ValueOf(b)
+	if !isNumber(x) || !isNumber(y) {
+		return 0, false
+	}
+
+	if i, ok := exactInt(x); ok {
+		if j, ok := exactInt(y); ok {
+			switch {
+			case i < j:
+				return -1, true
+			case i > j:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+
+	f, g := toFloat(x), toFloat(y)
+	switch {
+	case f < g:
+		return -1, true
+	case f > g:
+		return 1, true
+	}
+	return 0, true
+}
+
+func isNumber(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func exactInt(v reflect.Value) (int64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() <= math.MaxInt64 {
+			return int64(v.Uint()), true
+		}
+	}
+	return 0, false
+}
+
+func toFloat(v reflect.Value) float64 {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint())
+	}
+	return v.Float()
+}
+
+// queryParser reads a JSONPath expression.
+type queryParser struct {
+	src string
+	pos int
+}
+
+func (p *queryParser) errorf(format string, a ...any) error {
+	return fmt.Errorf("%w: %s at offset %d of %q", ErrInvalidQuery, fmt.Sprintf(format, a...), p.pos, p.src)
+}
+
+func (p *queryParser) peek() byte {
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+func (p *queryParser) skipSpace() {
+	for p.pos < len(p.src) && strings.IndexByte(" \t\n\r", p.src[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// accept consumes s if the input continues with it.
+func (p *queryParser) accept(s string) bool {
+	if strings.HasPrefix(p.src[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *queryParser) expect(s string) error {
+	if !p.accept(s) {
+		return p.errorf("expected %q", s)
+	}
+	return nil
+}
+
+// parseQuery reads a query starting with root, '$' or '@'.
+func (p *queryParser) parseQuery(root byte) (*pathQuery, error) {
+	if p.peek() != root {
+		return nil, p.errorf("expected '%c'", root)
+	}
+	p.pos++
+
+	query := &pathQuery{relative: root == '@'}
+	for {
+		save := p.pos
+		p.skipSpace()
+
+		var seg querySegment
+		switch {
+		case p.accept(".."):
+			seg.descendant = true
+			if p.peek() == '[' {
+				selectors, err := p.parseBracket()
+				if err != nil {
+					return nil, err
+				}
+				seg.selectors = selectors
+			} else {
+				sel, err := p.parseShorthand()
+				if err != nil {
+					return nil, err
+				}
+				seg.selectors = []selector{sel}
+			}
+		case p.accept("."):
+			sel, err := p.parseShorthand()
+			if err != nil {
+				return nil, err
+			}
+			seg.selectors = []selector{sel}
+		case p.peek() == '[':
+			selectors, err := p.parseBracket()
+			if err != nil {
+				return nil, err
+			}
+			seg.selectors = selectors
+		default:
+			p.pos = save
+			return query, nil
+		}
+		query.segments = append(query.segments, seg)
+	}
+}
+
+// parseShorthand reads the '*' or the member name after '.' or '..'.
+func (p *queryParser) parseShorthand() (selector, error) {
+	if p.accept("*") {
+		return selector{kind: wildcardSelector}, nil
+	}
+
+	start := p.pos
+	for p.pos < len(p.src) {
+		char, size := utf8.DecodeRuneInString(p.src[p.pos:])
+		if !(char == '_' || char >= 0x80 || 'a' <= char && char <= 'z' || 'A' <= char && char <= 'Z' ||
+			p.pos > start && '0' <= char && char <= '9') {
+			break
+		}
+		p.pos += size
+	}
+	if p.pos == start {
+		return selector{}, p.errorf("expected a member name")
+	}
+	return selector{kind: nameSelector, name: p.src[start:p.pos]}, nil
+}
+
+// parseBracket reads a bracketed list of selectors.
+func (p *queryParser) parseBracket() ([]selector, error) {
+	p.pos++ // '['
+	var selectors []selector
+	for {
+		p.skipSpace()
+		sel, err := p.parseSelector()
+		if err != nil {
+			return nil, err
+		}
+		selectors = append(selectors, sel)
+
+		p.skipSpace()
+		if p.accept("]") {
+			return selectors, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *queryParser) parseSelector() (selector, error) {
+	switch char := p.peek(); {
+	case char == '\'' || char == '"':
+		name, err := p.parseString()
+		return selector{kind: nameSelector, name: name}, err
+	case char == '*':
+		p.pos++
+		return selector{kind: wildcardSelector}, nil
+	case char == '?':
+		p.pos++
+		p.skipSpace()
+		filter, err := p.parseOr()
+		return selector{kind: filterSelector, filter: filter}, err
+	}
+
+	sel := selector{kind: indexSelector, step: 1}
+	if p.peek() != ':' {
+		index, err := p.parseInt()
+		if err != nil {
+			return sel, err
+		}
+		sel.index, sel.start, sel.hasStart = index, index, true
+		p.skipSpace()
+		if p.peek() != ':' {
+			return sel, nil
+		}
+	}
+
+	sel.kind = sliceSelector
+	p.pos++ // ':'
+	p.skipSpace()
+	if c := p.peek(); c == '-' || '0' <= c && c <= '9' {
+		end, err := p.parseInt()
+		if err != nil {
+			return sel, err
+		}
+		sel.end, sel.hasEnd = end, true
+		p.skipSpace()
+	}
+	if p.accept(":") {
+		p.skipSpace()
+		if c := p.peek(); c == '-' || '0' <= c && c <= '9' {
+			step, err := p.parseInt()
+			if err != nil {
+				return sel, err
+			}
+			sel.step = step
+		}
+	}
+	return sel, nil
+}
+
+// parseInt reads an index: no leading zeros, no "-0", and within the exact
+// integer range of IEEE 754 doubles.
+func (p *queryParser) parseInt() (int, error) {
+	start := p.pos
+	p.accept("-")
+	digits := p.pos
+	for '0' <= p.peek() && p.peek() <= '9' {
+		p.pos++
+	}
+
+	text := p.src[start:p.pos]
+	if p.pos == digits || p.src[digits] == '0' && (p.pos-digits > 1 || digits > start) {
+		p.pos = start
+		return 0, p.errorf("invalid integer")
+	}
+	i, err := strconv.ParseInt(text, 10, 64)
+	if err != nil || i > 1<<53-1 || i < -(1<<53-1) {
+		p.pos = start
+		return 0, p.errorf("integer %s out of range", text)
+	}
+	return int(i), nil
+}
+
+// parseString reads a string literal in single or double quotes.
+func (p *queryParser) parseString() (string, error) {
+	quote := p.src[p.pos]
+	p.pos++
+
+	var b strings.Builder
+	for {
+		if p.pos >= len(p.src) {
+			return "", p.errorf("unterminated string")
+		}
+		char := p.src[p.pos]
+		switch {
+		case char == quote:
+			p.pos++
+			return b.String(), nil
+		case char < 0x20:
+			return "", p.errorf("control character in string")
+		case char != '\\':
+			b.WriteByte(char)
+			p.pos++
+			continue
+		}
+
+		p.pos++
+		escape := p.peek()
+		p.pos++
+		switch escape {
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case '/', '\\':
+			b.WriteByte(escape)
+		case 'u':
+			r, err := p.parseHex()
+			if err != nil {
+				return "", err
+			}
+			if utf16.IsSurrogate(r) {
+				if !p.accept(`\u`) {
+					return "", p.errorf("unpaired surrogate")
+				}
+				low, err := p.parseHex()
+				if err != nil {
+					return "", err
+				}
+				if r = utf16.DecodeRune(r, low); r == utf8.RuneError {
+					return "", p.errorf("invalid surrogate pair")
+				}
+			}
+			b.WriteRune(r)
+		default:
+			if escape != quote {
+				p.pos -= 2
+				return "", p.errorf("invalid escape")
+			}
+			b.WriteByte(escape)
+		}
+	}
+}
+
+func (p *queryParser) parseHex() (rune, error) {
+	if p.pos+4 > len(p.src) {
+		return 0, p.errorf("invalid \\u escape")
+	}
+	r, err := strconv.ParseUint(p.src[p.pos:p.pos+4], 16, 32)
+	if err != nil {
+		return 0, p.errorf("invalid \\u escape")
+	}
+	p.pos += 4
+	return rune(r), nil
+}
+
+func (p *queryParser) parseOr() (logicalExpr, error) {
+	var or orExpr
+	for {
+		and, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		or = append(or, and)
+
+		p.skipSpace()
+		if !p.accept("||") {
+			break
+		}
+		p.skipSpace()
+	}
+	if len(or) == 1 {
+		return or[0], nil
+	}
+	return or, nil
+}
+
+func (p *queryParser) parseAnd() (logicalExpr, error) {
+	var and andExpr
+	for {
+		basic, err := p.parseBasic()
+		if err != nil {
+			return nil, err
+		}
+		and = append(and, basic)
+
+		save := p.pos
+		p.skipSpace()
+		if !p.accept("&&") {
+			p.pos = save
+			break
+		}
+		p.skipSpace()
+	}
+	if len(and) == 1 {
+		return and[0], nil
+	}
+	return and, nil
+}
+
+// parseBasic reads a parenthesized expression, a negation, a comparison or a
+// test of a query or function.
+func (p *queryParser) parseBasic() (logicalExpr, error) {
+	if p.accept("!") {
+		p.skipSpace()
+		if p.accept("(") {
+			expr, err := p.parseParen()
+			return notExpr{expr}, err
+		}
+		expr, err := p.parseTest()
+		return notExpr{expr}, err
+	}
+	if p.accept("(") {
+		return p.parseParen()
+	}
+
+	start := p.pos
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	save := p.pos
+	p.skipSpace()
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if !p.accept(op) {
+			continue
+		}
+		p.skipSpace()
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.comparable(left); err != nil {
+			return nil, err
+		}
+		if err := p.comparable(right); err != nil {
+			return nil, err
+		}
+		return compareExpr{op: op, left: left, right: right}, nil
+	}
+
+	p.pos = save
+	if _, ok := left.(literal); ok {
+		p.pos = start
+		return nil, p.errorf("a literal must be compared")
+	}
+	p.pos = start
+	return p.parseTest()
+}
+
+func (p *queryParser) parseParen() (logicalExpr, error) {
+	p.skipSpace()
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	return expr, p.expect(")")
+}
+
+// parseTest reads a query tested for existence or a function returning a
+// logical value.
+func (p *queryParser) parseTest() (logicalExpr, error) {
+	operand, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	switch t := operand.(type) {
+	case queryOperand:
+		return existExpr{t.query}, nil
+	case *functionCall:
+		if t.kind() == logicalType {
+			return functionExpr{t}, nil
+		}
+		return nil, p.errorf("result of %s() must be compared", t.name)
+	}
+	return nil, p.errorf("a literal must be compared")
+}
+
+// comparable checks that an operand of a comparison has a single value.
+func (p *queryParser) comparable(operand operand) error {
+	switch t := operand.(type) {
+	case queryOperand:
+		if !t.query.singular() {
+			return p.errorf("query in a comparison must select a single value")
+		}
+	case *functionCall:
+		if t.kind() != valueType {
+			return p.errorf("result of %s() cannot be compared", t.name)
+		}
+	}
+	return nil
+}
+
+// parseOperand reads a literal, a query or a function call.
+func (p *queryParser) parseOperand() (operand, error) {
+	switch char := p.peek(); {
+	case char == '@' || char == '$':
+		query, err := p.parseQuery(char)
+		if err != nil {
+			return nil, err
+		}
+		return queryOperand{query}, nil
+	case char == '\'' || char == '"':
+		s, err := p.parseString()
+		return literal{s}, err
+	case char == '-' || '0' <= char && char <= '9':
+		return p.parseNumber()
+	case p.accept("true"):
+		return literal{true}, nil
+	case p.accept("false"):
+		return literal{false}, nil
+	case p.accept("null"):
+		return literal{nil}, nil
+	case 'a' <= char && char <= 'z':
+		return p.parseFunction()
+	}
+	return nil, p.errorf("expected a literal, a query or a function")
+}
+
+func (p *queryParser) parseNumber() (operand, error) {
+	start := p.pos
+	p.accept("-")
+	for '0' <= p.peek() && p.peek() <= '9' || strings.IndexByte(".eE+-", p.peek()) >= 0 {
+		p.pos++
+	}
+
+	text := p.src[start:p.pos]
+	digits := strings.TrimPrefix(text, "-")
+	if len(digits) > 1 && digits[0] == '0' && digits[1] != '.' && digits[1] != 'e' && digits[1] != 'E' {
+		p.pos = start
+		return nil, p.errorf("invalid number")
+	}
+	if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return literal{i}, nil
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil || strings.HasSuffix(text, ".") || strings.Contains(text, ".e") || strings.Contains(text, ".E") {
+		p.pos = start
+		return nil, p.errorf("invalid number")
+	}
+	return literal{f}, nil
+}
+
+func (p *queryParser) parseFunction() (operand, error) {
+	start := p.pos
+	for c := p.peek(); 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '_'; c = p.peek() {
+		p.pos++
+	}
+	name := p.src[start:p.pos]
+	signature, ok := queryFunctions[name]
+	if !ok || !p.accept("(") {
+		p.pos = start
+		return nil, p.errorf("unknown function %q", name)
+	}
+
+	call := &functionCall{name: name}
+	for i := range signature.params {
+		p.skipSpace()
+		if i > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+			p.skipSpace()
+		}
+
+		arg, err := p.parseArgument()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.checkArgument(call.name, signature.params[i], arg); err != nil {
+			return nil, err
+		}
+		call.args = append(call.args, arg)
+	}
+	p.skipSpace()
+	return call, p.expect(")")
+}
+
+// parseArgument reads a function argument: a literal, a query, a function
+// call or a logical expression.
+func (p *queryParser) parseArgument() (operand, error) {
+	start := p.pos
+	if c := p.peek(); c != '!' && c != '(' {
+		operand, err := p.parseOperand()
+		if err == nil {
+			save := p.pos
+			p.skipSpace()
+			if c := p.peek(); c == ',' || c == ')' {
+				p.pos = save
+				return operand, nil
+			}
+		}
+		p.pos = start
+	}
+
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	return logicalOperand{expr}, nil
+}
+
+// checkArgument checks an argument against the declared parameter type, RFC
+// 9535 section 2.4.3.
+func (p *queryParser) checkArgument(name string, param operandType, arg operand) error {
+	ok := false
+	switch param {
+	case valueType:
+		switch t := arg.(type) {
+		case literal:
+			ok = true
+		case queryOperand:
+			ok = t.query.singular()
+		case *functionCall:
+			ok = t.kind() == valueType
+		}
+	case nodesType:
+		_, ok = arg.(queryOperand)
+	case logicalType:
+		ok = arg.kind() != valueType
+	}
+	if !ok {
+		return p.errorf("invalid argument for %s()", name)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package json
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// store is the example document of RFC 9535, section 1.5.
+var store = `{ "store": {
+    "book": [
+      { "category": "reference", "author": "Nigel Rees", "title": "Sayings of the Century", "price": 8.95 },
+      { "category": "fiction", "author": "Evelyn Waugh", "title": "Sword of Honour", "price": 12.99 },
+      { "category": "fiction", "author": "Herman Melville", "title": "Moby Dick", "isbn": "0-553-21311-3", "price": 8.99 },
+      { "category": "fiction", "author": "J. R. R. Tolkien", "title": "The Lord of the Rings", "isbn": "0-395-19395-8", "price": 22.99 }
+    ],
+    "bicycle": { "color": "red", "price": 399 }
+  }
+}`
+
+func TestQuery(t *testing.T) {
+	c := Parse(store, KeepOrder())
+
+	tests := []struct {
+		expr  string
+		paths string
+	}{
+		{`$.store.book[*].author`, `[$['store']['book'][0]['author'] $['store']['book'][1]['author'] $['store']['book'][2]['author'] $['store']['book'][3]['author']]`},
+		{`$..author`, `[$['store']['book'][0]['author'] $['store']['book'][1]['author'] $['store']['book'][2]['author'] $['store']['book'][3]['author']]`},
+		{`$.store.*`, `[$['store']['book'] $['store']['bicycle']]`},
+		{`$.store..price`, `[$['store']['book'][0]['price'] $['store']['book'][1]['price'] $['store']['book'][2]['price'] $['store']['book'][3]['price'] $['store']['bicycle']['price']]`},
+		{`$..book[2]`, `[$['store']['book'][2]]`},
+		{`$..book[-1]`, `[$['store']['book'][3]]`},
+		{`$..book[0,1]`, `[$['store']['book'][0] $['store']['book'][1]]`},
+		{`$..book[:2]`, `[$['store']['book'][0] $['store']['book'][1]]`},
+		{`$..book[::-2]`, `[$['store']['book'][3] $['store']['book'][1]]`},
+		{`$..book[?@.isbn]`, `[$['store']['book'][2] $['store']['book'][3]]`},
+		{`$..book[?(@.price < 10)]`, `[$['store']['book'][0] $['store']['book'][2]]`},
+		{`$..book[?@.price<10 && !(@.category == 'reference')]`, `[$['store']['book'][2]]`},
+		{`$..book[?@.price > $.store.bicycle.price || @.author == "Evelyn Waugh"]`, `[$['store']['book'][1]]`},
+		{`$..book[?length(@.title) > 20]`, `[$['store']['book'][0] $['store']['book'][3]]`},
+		{`$..book[?match(@.author, 'J.*')]`, `[$['store']['book'][3]]`},
+		{`$..book[?search(@.title, "of the")]`, `[$['store']['book'][0] $['store']['book'][3]]`},
+		{`$.store[?count(@.*) == 2]`, `[$['store']['bicycle']]`},
+		{`$.store[?value(@..color) == 'red']`, `[$['store']['bicycle']]`},
+		{`$..*[?@ == 399]`, `[$['store']['bicycle']['price']]`},
+		{`$['store']['bicycle', 'missing']`, `[$['store']['bicycle']]`},
+		{`$.store.book[1:3].title`, `[$['store']['book'][1]['title'] $['store']['book'][2]['title']]`},
+		{`$.store.book[5]`, `[]`},
+		{`$`, `[$]`},
+	}
+	for _, test := range tests {
+		results, err := c.Query(test.expr)
+		if err != nil {
+			t.Errorf("Query(%s): %v", test.expr, err)
+			continue
+		}
+		var paths []string
+		for _, r := range results {
+			paths = append(paths, r.Path)
+		}
+		if fmt.Sprint(paths) != test.paths {
+			t.Errorf("Query(%s) = %v, want %s", test.expr, paths, test.paths)
+		}
+	}
+
+	results, _ := c.Query(`$..book[?@.price == 8.99].title`)
+	if len(results) != 1 || results[0].Value.Data() != "Moby Dick" {
+		t.Fatalf("Query(price == 8.99) = %v", results)
+	}
+	if got := c.Search(results[0].Hierarchy...).Data(); got != "Moby Dick" {
+		t.Errorf("Search(%v) = %v", results[0].Hierarchy, got)
+	}
+}
+
+func TestQueryComparisons(t *testing.T) {
+	c := Parse(`{"a": [1, 2.0, "2", null, true, [1], {"k": 1}, "b"], "o": {"x": {"k": 1}}}`)
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{`$.a[?@ == 2]`, `[2]`},
+		{`$.a[?@ == '2']`, `["2"]`},
+		{`$.a[?@ == null]`, `[null]`},
+		{`$.a[?@ == [1]]`, ``},
+		{`$.a[?@ == $.o.x]`, `[{"k":1}]`},
+		{`$.a[?@ < 'c']`, `["2","b"]`},
+		{`$.a[?@ >= 1]`, `[1,2]`},
+		{`$.a[?@.k]`, `[{"k":1}]`},
+		{`$.a[?@.missing == @.other]`, `[1,2,"2",null,true,[1],{"k":1},"b"]`},
+		{`$.a[?length(@) == 1]`, `["2",[1],{"k":1},"b"]`},
+	}
+	for _, test := range tests {
+		results, err := c.Query(test.expr)
+		if err != nil {
+			if test.want != "" {
+				t.Errorf("Query(%s): %v", test.expr, err)
+			}
+			continue
+		}
+		var values []any
+		for _, r := range results {
+			values = append(values, r.Value.Data())
+		}
+		if got := Marshal(values); got != test.want {
+			t.Errorf("Query(%s) = %s, want %s", test.expr, got, test.want)
+		}
+	}
+}
+
+func TestQueryInvalid(t *testing.T) {
+	c := Parse(store)
+	for _, expr := range []string{
+		``, `store`, `$.`, `$[`, `$[01]`, `$[-0]`, `$['a`, `$[?@.a ==]`, `$[?1]`, `$[?@.* == 1]`,
+		`$[?count(@.a) == 1 == 2]`, `$[?length(@.*) == 1]`, `$[?match(@.a) ]`, `$[?foo(@)]`,
+		`$[?length(@.a)]`, `$[?count(1) == 1]`, `$.a `, `$..`, `$[9007199254740992]`,
+	} {
+		if _, err := c.Query(expr); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("Query(%s) = %v, want ErrInvalidQuery", expr, err)
+		}
+	}
+}
@@ -1,28 +1,208 @@
 package lexer
 
 import (
+	"bufio"
+	"fmt"
+	"io"
 	"strings"
 	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
 
 	"github.com/qw20012/go-json/token"
 )
 
+// SyntaxError describes malformed input found by the lexer or the parser.
+type SyntaxError struct {
+	Msg    string    // description of the error
+	Pos    token.Pos // where the error was found
+	File   string    // name of the input, if known
+	Source string    // the input line containing Pos
+}
+
+// Error formats the error as "file:line:column: message", followed by the
+// offending source line with a caret under the column.
+func (e *SyntaxError) Error() string {
+	msg := e.Msg
+	if e.Pos.Line > 0 {
+		msg = fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+	}
+	if e.File != "" {
+		msg = e.File + ":" + msg
+	}
+	if e.Source == "" {
+		return msg
+	}
+
+	var caret strings.Builder
+	for i, char := range []rune(e.Source) {
+		if i >= e.Pos.Column-1 {
+			break
+		}
+		if char == '\t' {
+			caret.WriteRune('\t')
+		} else {
+			caret.WriteRune(' ')
+		}
+	}
+	caret.WriteRune('^')
+
+	return msg + "\n" + e.Source + "\n" + caret.String()
+}
+
 type Lexer struct {
-	input []rune // use 'rune' to handle Unicode
-	start int
-	end   int
-	char  rune
+	input    []rune  // use 'rune' to handle Unicode
+	sizes    []uint8 // size in bytes of each rune of input in the source
+	start    int
+	end      int
+	char     rune
+	err      *SyntaxError // set when an INVALID token is returned
+	strict   bool         // accept RFC 8259 JSON only
+	comments bool         // return comments as COMMENT tokens
+	value    []rune       // value of the last string scanned, unescaped
+
+	// The outer braces may be omitted from the input, in which case they
+	// are emitted around it without touching the input.
+	openBrace  bool
+	closeBrace bool
+
+	// position cache: mark is an index into input and markPos its position.
+	// base is the position of input[0], which moves as a reader lexer
+	// discards consumed input.
+	mark    int
+	markPos token.Pos
+	base    token.Pos
+
+	// A reader lexer reads input from reader as it is needed. The character
+	// after a token is read only when the next token is asked for, so that
+	// a token never waits for input beyond it.
+	reader  *bufio.Reader
+	readErr error
+	pending bool
+	peeking bool
 }
 
+// discardSize is how much consumed input a reader lexer keeps before it
+// discards it.
+const discardSize = 1 << 16
+
 func NewLexer(input []byte) *Lexer {
-	l := &Lexer{input: []rune(string(input))}
+	l := &Lexer{}
+	l.input, l.sizes = decode(input)
+	l.base = token.Pos{Line: 1, Column: 1}
+	l.markPos = l.base
 	l.readChar()
 	l.addBraceIfNeed()
 	return l
 }
 
+// decode returns the runes of input with their sizes in bytes. An invalid
+// byte becomes utf8.RuneError but still takes one byte, so offsets computed
+// from the sizes stay byte offsets.
+func decode(input []byte) ([]rune, []uint8) {
+	runes := make([]rune, 0, len(input))
+	sizes := make([]uint8, 0, len(input))
+	for len(input) > 0 {
+		char, size := utf8.DecodeRune(input)
+		runes = append(runes, char)
+		sizes = append(sizes, uint8(size))
+		input = input[size:]
+	}
+	return runes, sizes
+}
+
+// NewStrictLexer returns a lexer that accepts RFC 8259 JSON only: comments,
+// unquoted strings, omitted outer braces and numbers with leading zeros are
+// reported as errors.
+func NewStrictLexer(input []byte) *Lexer {
+	l := &Lexer{strict: true}
+	l.input, l.sizes = decode(input)
+	l.base = token.Pos{Line: 1, Column: 1}
+	l.markPos = l.base
+	l.readChar()
+	return l
+}
+
+// NewReaderLexer returns a lexer that reads its input from r as tokens are
+// asked for, keeping only a bounded window of it in memory. The input may
+// hold several values one after the other; unlike NewLexer, outer braces are
+// never implied.
+func NewReaderLexer(r io.Reader) *Lexer {
+	l := &Lexer{reader: bufio.NewReader(r), pending: true}
+	l.base = token.Pos{Line: 1, Column: 1}
+	l.markPos = l.base
+	return l
+}
+
+// NewStrictReaderLexer is like NewReaderLexer but accepts RFC 8259 JSON only,
+// as NewStrictLexer does.
+func NewStrictReaderLexer(r io.Reader) *Lexer {
+	l := NewReaderLexer(r)
+	l.strict = true
+	return l
+}
+
+// EmitComments makes NewToken return each comment as a COMMENT token holding
+// its text, instead of skipping it. A parser does not expect such tokens; this
+// is meant for tools that rewrite the input.
+func (l *Lexer) EmitComments() {
+	l.comments = true
+}
+
+// Strict reports whether the lexer accepts RFC 8259 JSON only.
+func (l *Lexer) Strict() bool {
+	return l.strict
+}
+
+// has reports whether input[index] exists, reading up to it from the reader
+// of a reader lexer if needed.
+func (l *Lexer) has(index int) bool {
+	for index >= len(l.input) {
+		if l.reader == nil || l.readErr != nil {
+			return false
+		}
+
+		char, size, err := l.reader.ReadRune()
+		if err != nil {
+			if err != io.EOF {
+				l.readErr = err
+			}
+			l.reader = nil
+			return false
+		}
+		l.input = append(l.input, char)
+		l.sizes = append(l.sizes, uint8(size))
+	}
+	return true
+}
+
+// discard drops the input of a reader lexer before the current token, or
+// before the line it is on when that line is short, once enough of it has
+// been consumed.
+func (l *Lexer) discard() {
+	if l.reader == nil || l.peeking || l.start < discardSize {
+		return
+	}
+
+	cut := l.start
+	for i := l.start - 1; i >= 0 && i >= l.start-256; i-- {
+		if l.input[i] == '\n' {
+			cut = i + 1
+			break
+		}
+	}
+
+	l.base = l.position(cut)
+	l.input = l.input[:copy(l.input, l.input[cut:])]
+	l.sizes = l.sizes[:copy(l.sizes, l.sizes[cut:])]
+	l.start -= cut
+	l.end -= cut
+	l.mark = 0
+	l.markPos = l.base
+}
+
 func (l *Lexer) readChar() {
-	if l.end < len(l.input) {
+	if l.has(l.end) {
 		l.char = l.input[l.end]
 	} else {
 		l.char = 0
@@ -31,25 +211,125 @@ func (l *Lexer) readChar() {
 	l.end += 1
 }
 
-func (l *Lexer) addBraceIfNeed() {
-	start := l.start
-	end := l.end
+// peekChar returns the character after the current one without consuming it,
+// or 0 at the end of input.
+func (l *Lexer) peekChar() rune {
+	if l.has(l.end) {
+		return l.input[l.end]
+	}
+	return 0
+}
+
+func (l *Lexer) atEOF() bool {
+	return !l.has(l.start)
+}
+
+// position returns the position of input[index], moving the cached mark
+// forward from its last place so that in-order lookups stay linear.
+func (l *Lexer) position(index int) token.Pos {
+	if index < l.mark {
+		l.mark = 0
+		l.markPos = l.base
+	}
 
+	for ; l.mark < index && l.mark < len(l.input); l.mark++ {
+		char := l.input[l.mark]
+		l.markPos.Offset += int(l.sizes[l.mark])
+		if char == '\n' {
+			l.markPos.Line += 1
+			l.markPos.Column = 1
+		} else {
+			l.markPos.Column += 1
+		}
+	}
+	return l.markPos
+}
+
+// sourceLine returns the text of the given 1-based input line, as far as it
+// has been read.
+func (l *Lexer) sourceLine(line int) string {
+	if line < l.base.Line {
+		return ""
+	}
+
+	begin := 0
+	for i := 0; i < len(l.input) && line > l.base.Line; i++ {
+		if l.input[i] == '\n' {
+			line -= 1
+			begin = i + 1
+		}
+	}
+
+	end := begin
+	for end < len(l.input) && l.input[end] != '\n' {
+		end += 1
+	}
+	return strings.TrimSuffix(string(l.input[begin:end]), "\r")
+}
+
+func (l *Lexer) addBraceIfNeed() {
+	saved := *l
 	if l.NewToken().Type == token.STRING && l.NewToken().Type == token.COLON {
-		str := append([]byte("{"), string(l.input)...)
-		str = append(str, "}"...)
-		l.input = []rune(string(str))
+		saved.openBrace = true
+		saved.closeBrace = true
 	}
+	*l = saved
+}
 
-	l.start = start
-	l.end = end
-	l.char = l.input[l.end-1]
+// Err returns the error behind the last INVALID token, or nil. An error
+// reading the input of a reader lexer takes precedence.
+func (l *Lexer) Err() error {
+	if l.readErr != nil {
+		return l.readErr
+	}
+	if l.err == nil {
+		return nil
+	}
+	return l.err
+}
+
+// Errorf returns a *SyntaxError at pos, quoting the input line it falls on.
+func (l *Lexer) Errorf(pos token.Pos, format string, a ...any) *SyntaxError {
+	return &SyntaxError{
+		Msg:    fmt.Sprintf(format, a...),
+		Pos:    pos,
+		Source: l.sourceLine(pos.Line),
+	}
+}
+
+func (l *Lexer) fail(index int, msg string) {
+	if l.err == nil {
+		l.err = l.Errorf(l.position(index), "%s", msg)
+	}
 }
 
 func (l *Lexer) NewToken() token.Token {
 	var tok token.Token
+	if l.pending {
+		l.pending = false
+		l.readChar()
+	}
 	skipWhitespace(l)
+	if l.comments && isComment(l) && !l.strict {
+		return commentToken(l)
+	}
 	skipComments(l)
+	l.discard()
+	pos := l.position(l.start)
+
+	if l.err != nil || l.readErr != nil {
+		tok = token.NewToken(token.INVALID, "")
+		tok.Pos, tok.End = pos, pos
+		return tok
+	}
+
+	if l.openBrace {
+		l.openBrace = false
+		tok = token.NewToken(token.LBRACE, "{")
+		tok.Pos = l.position(0)
+		tok.End = tok.Pos
+		return tok
+	}
 
 	switch l.char {
 	case ':':
@@ -65,116 +345,279 @@ func (l *Lexer) NewToken() token.Token {
 	case ']':
 		tok = token.NewToken(token.RBRACKET, string(l.char))
 	default:
-		if isInteger(l) {
-			tok = token.NewToken(token.INTEGER, string(l.input[l.start:l.end]))
+		if l.atEOF() && l.closeBrace {
+			l.closeBrace = false
+			tok = token.NewToken(token.RBRACE, "}")
+		} else if l.atEOF() {
+			tok = token.NewToken(token.EOF, "")
+		} else if isNumber(l) {
+			lit := string(l.input[l.start:l.end])
+			if strings.ContainsAny(lit, ".eE") {
+				tok = token.NewToken(token.FLOAT, lit)
+			} else {
+				tok = token.NewToken(token.INTEGER, lit)
+			}
+		} else if l.err != nil {
+			tok = token.NewToken(token.INVALID, string(l.input[l.start:l.end]))
 		} else if isBoolean(l) {
 			tok = token.NewToken(token.BOOLEAN, string(l.input[l.start:l.end]))
+		} else if isNull(l) {
+			tok = token.NewToken(token.NULL, string(l.input[l.start:l.end]))
 		} else if isString(l) {
-			str := string(l.input[l.start:l.end])
-			str = strings.Trim(str, `"`)
-			tok = token.NewToken(token.STRING, str)
-		} else if l.char == rune(0) {
-			tok = token.NewToken(token.EOF, "")
+			tok = token.NewToken(token.STRING, string(l.value))
 		} else {
-			tok = token.NewToken(token.INVALID, string(l.char))
+			tok = token.NewToken(token.INVALID, string(l.input[l.start:l.end]))
 		}
 	}
 
-	l.readChar()
+	tok.Pos, tok.End = pos, pos
+	if tok.Type != token.EOF && !(tok.Type == token.RBRACE && l.atEOF()) {
+		tok.End = l.position(l.end)
+	}
+	if l.reader != nil {
+		l.pending = true
+	} else {
+		l.readChar()
+	}
 	return tok
 }
 
 func (l *Lexer) PeakToken() token.Token {
-	start := l.start
-	end := l.end
+	saved := *l
+	l.peeking = true
 	tok := l.NewToken()
 
-	l.start = start
-	l.end = end
-	l.char = l.input[l.end-1]
+	// Keep what a reader lexer has read meanwhile, and whether the reader
+	// reached its end.
+	saved.input, saved.sizes, saved.reader, saved.readErr = l.input, l.sizes, l.reader, l.readErr
+	*l = saved
 	return tok
 }
 
-func isInteger(l *Lexer) bool {
-	if !unicode.IsDigit(l.char) {
+// isDelimiter reports whether char ends an unquoted literal.
+func isDelimiter(char rune) bool {
+	return unicode.IsSpace(char) || char == ':' || char == ',' || char == '}' || char == ']'
+}
+
+// endsLiteral reports whether the literal in input[l.start:end] is followed by
+// a delimiter or the end of input.
+func endsLiteral(l *Lexer, end int) bool {
+	return !l.has(end) || isDelimiter(l.input[end])
+}
+
+func isDigit(char rune) bool {
+	return '0' <= char && char <= '9'
+}
+
+// isNumber scans a number as defined by RFC 8259:
+//
+//	[ "-" ] int [ "." 1*DIGIT ] [ ( "e" / "E" ) [ "-" / "+" ] 1*DIGIT ]
+//
+// where int may have leading zeros unless the lexer is strict. A literal that
+// stops being a number before its end is an unquoted string; one that ends
+// while a part of the number is still missing, such as "1." or "2e", is
+// reported as an invalid number.
+func isNumber(l *Lexer) bool {
+	if l.char != '-' && !isDigit(l.char) {
 		return false
 	}
 
-	endIndex := l.end
-	for {
-		l.char = l.input[endIndex]
-		if !unicode.IsDigit(l.char) {
-			break
+	i := l.start
+	digits := func() int {
+		count := 0
+		for l.has(i) && isDigit(l.input[i]) {
+			i += 1
+			count += 1
 		}
-		endIndex += 1
-		if endIndex == len(l.input) {
-			break
+		return count
+	}
+	next := func(chars string) bool {
+		if l.has(i) && strings.ContainsRune(chars, l.input[i]) {
+			i += 1
+			return true
 		}
+		return false
+	}
+	malformed := func() bool {
+		end := i
+		for l.has(end) && !isDelimiter(l.input[end]) {
+			end += 1
+		}
+		if l.strict || end == i {
+			l.end = end
+			l.fail(l.start, fmt.Sprintf("invalid number %q", string(l.input[l.start:end])))
+		}
+		return false
 	}
 
-	if !unicode.IsSpace(l.char) && l.char != ']' && l.char != ',' &&
-		l.char != '}' && endIndex < len(l.input) {
+	next("-")
+	intStart := i
+	if digits() == 0 {
+		return malformed()
+	}
+	if l.strict && l.input[intStart] == '0' && i-intStart > 1 {
+		l.end = i
+		l.fail(l.start, fmt.Sprintf("invalid number %q: leading zero", string(l.input[l.start:i])))
 		return false
 	}
+	if next(".") && digits() == 0 {
+		return malformed()
+	}
+	if next("eE") {
+		next("+-")
+		if digits() == 0 {
+			return malformed()
+		}
+	}
 
-	l.end = endIndex
+	if !endsLiteral(l, i) {
+		return malformed()
+	}
 
+	l.end = i
 	return true
 }
 
 func isBoolean(l *Lexer) bool {
-	return isTrueOrFalse(l, []rune("true")) || isTrueOrFalse(l, []rune("false"))
+	return isKeyword(l, []rune("true")) || isKeyword(l, []rune("false"))
 }
 
-func isTrueOrFalse(l *Lexer, value []rune) bool {
-	oldEnd := l.end
-	oldChar := l.char
+func isNull(l *Lexer) bool {
+	return isKeyword(l, []rune("null"))
+}
 
-	for i := 0; i < len(value) && l.end < len(l.input) && value[i] == l.char; i++ {
-		if i == len(value)-1 {
-			return true
-		}
+// isKeyword reports whether the input at the current character is the
+// unquoted word value, and if so consumes it.
+func isKeyword(l *Lexer, value []rune) bool {
+	end := l.start + len(value)
+	if !l.has(end-1) || string(l.input[l.start:end]) != string(value) {
+		return false
+	}
 
-		l.char = l.input[l.end]
-		l.end += 1
+	if !endsLiteral(l, end) {
+		return false
 	}
 
-	l.end = oldEnd
-	l.char = oldChar
-	return false
+	l.end = end
+	return true
 }
 
+// isString scans an unquoted string up to the next delimiter, or a quoted
+// string and its escape sequences, leaving the value of the string in l.value.
 func isString(l *Lexer) bool {
-	beginQuote := false
-	if l.char == '"' {
-		beginQuote = true
+	if l.char != '"' {
+		for l.has(l.end) && !isDelimiter(l.input[l.end]) {
+			l.end += 1
+		}
+		if l.strict {
+			l.fail(l.start, fmt.Sprintf("unquoted string %q", string(l.input[l.start:l.end])))
+			return false
+		}
+		l.value = append(l.value[:0], l.input[l.start:l.end]...)
+		return true
 	}
 
-	for l.end < len(l.input) {
-		l.char = l.input[l.end]
+	l.value = l.value[:0]
+	for l.has(l.end) {
+		char := l.input[l.end]
+		l.end += 1
+
+		switch {
+		case char == '"':
+			return true
+		case char == '\\':
+			if !unescape(l) {
+				return false
+			}
+		case char < 0x20 && l.strict:
+			l.fail(l.end-1, fmt.Sprintf("invalid control character %q in string", char))
+			return false
+		default:
+			l.value = append(l.value, char)
+		}
+	}
+
+	l.fail(l.start, "unterminated string")
+	return false
+}
 
-		if !beginQuote {
+// unescape decodes the escape sequence following a backslash into l.value.
+// A \u escape of half a surrogate pair that is not followed by the other half
+// becomes U+FFFD, as in encoding/json.
+func unescape(l *Lexer) bool {
+	begin := l.end - 1
+	if !l.has(l.end) {
+		l.fail(l.start, "unterminated string")
+		return false
+	}
 
-			if unicode.IsSpace(l.char) || l.char == ':' || l.char == ',' ||
-				l.char == '}' {
+	char := l.input[l.end]
+	l.end += 1
 
-				return true
-			}
+	switch char {
+	case '"', '\\', '/':
+		l.value = append(l.value, char)
+	case 'b':
+		l.value = append(l.value, '\b')
+	case 'f':
+		l.value = append(l.value, '\f')
+	case 'n':
+		l.value = append(l.value, '\n')
+	case 'r':
+		l.value = append(l.value, '\r')
+	case 't':
+		l.value = append(l.value, '\t')
+	case 'u':
+		r, ok := hex4(l)
+		if !ok {
+			l.fail(begin, fmt.Sprintf("invalid unicode escape %q", string(l.input[begin:l.end])))
+			return false
+		}
 
-			if l.end == len(l.input)-1 {
-				l.end += 1
-				//fmt.Println("test:" + string(l.input[l.start:l.end]))
-				return true
+		if utf16.IsSurrogate(r) {
+			high := r
+			r = unicode.ReplacementChar
+			if l.has(l.end+1) && l.input[l.end] == '\\' && l.input[l.end+1] == 'u' {
+				end := l.end
+				l.end += 2
+				low, ok := hex4(l)
+				if combined := utf16.DecodeRune(high, low); ok && combined != unicode.ReplacementChar {
+					r = combined
+				} else {
+					l.end = end
+				}
 			}
-		} else if beginQuote && l.input[l.end] == '"' {
-			l.end += 1
-			return true
 		}
+		l.value = append(l.value, r)
+	default:
+		l.fail(begin, fmt.Sprintf("invalid escape sequence %q", string(l.input[begin:l.end])))
+		return false
+	}
+	return true
+}
 
-		l.end += 1
+// hex4 reads the four hex digits of a \u escape.
+func hex4(l *Lexer) (rune, bool) {
+	if !l.has(l.end + 3) {
+		l.end = len(l.input)
+		return 0, false
 	}
 
-	return false
+	var r rune
+	for _, char := range l.input[l.end : l.end+4] {
+		l.end += 1
+		switch {
+		case '0' <= char && char <= '9':
+			r = r<<4 | (char - '0')
+		case 'a' <= char && char <= 'f':
+			r = r<<4 | (char - 'a' + 10)
+		case 'A' <= char && char <= 'F':
+			r = r<<4 | (char - 'A' + 10)
+		default:
+			return 0, false
+		}
+	}
+	return r, true
 }
 
 func skipWhitespace(l *Lexer) {
@@ -186,25 +629,64 @@ func skipWhitespace(l *Lexer) {
 	}
 }
 
+// isComment reports whether a comment starts at the current character.
+func isComment(l *Lexer) bool {
+	return l.char == '/' && (l.peekChar() == '/' || l.peekChar() == '*')
+}
+
+// commentToken scans a single comment into a COMMENT token. The lexer is left
+// on the character after the comment, ready for the next token.
+func commentToken(l *Lexer) token.Token {
+	begin := l.start
+	pos := l.position(begin)
+	scanComment(l)
+	if l.err != nil {
+		tok := token.NewToken(token.INVALID, "")
+		tok.Pos, tok.End = pos, pos
+		return tok
+	}
+
+	tok := token.NewToken(token.COMMENT, string(l.input[begin:l.start]))
+	tok.Pos, tok.End = pos, l.position(l.start)
+	return tok
+}
+
 func skipComments(l *Lexer) {
-	if l.char != '/' || (l.input[l.end] != '/' && l.input[l.end] != '*') {
-		return
+	for isComment(l) {
+		if l.strict {
+			l.fail(l.start, "comments are not allowed")
+			return
+		}
+
+		if !scanComment(l) {
+			return
+		}
+		skipWhitespace(l)
 	}
+}
 
-	if l.input[l.end] == '/' {
-		l.readChar()
-		for l.char != '\n' {
+// scanComment consumes the comment at the current character, leaving the
+// lexer on the character after it: the newline ending a line comment, or the
+// one after the "*/" of a block comment.
+func scanComment(l *Lexer) bool {
+	begin := l.start
+	if l.peekChar() == '/' {
+		for l.char != '\n' && !l.atEOF() {
 			l.readChar()
 		}
-	} else if l.input[l.end] == '*' {
-		l.readChar()
-		for l.char != '*' || l.input[l.end] != '/' {
-			l.readChar()
+		return true
+	}
+
+	l.readChar()
+	l.readChar()
+	for l.char != '*' || l.peekChar() != '/' {
+		if l.atEOF() {
+			l.fail(begin, "unterminated block comment")
+			return false
 		}
 		l.readChar()
-		l.readChar()
 	}
-	skipWhitespace(l)
-
-	//fmt.Println(string(l.char))
+	l.readChar()
+	l.readChar()
+	return true
 }
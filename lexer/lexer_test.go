@@ -1,8 +1,11 @@
 package lexer
 
 import (
+	"io"
+	"reflect"
 	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/qw20012/go-json/token"
 )
@@ -163,3 +166,345 @@ true `
 	}
 
 }
+
+func TestKeywords(t *testing.T) {
+	tests := []struct {
+		typ token.Type
+		lit string
+	}{
+		{token.LBRACKET, "["},
+		{token.BOOLEAN, "true"},
+		{token.COMMA, ","},
+		{token.NULL, "null"},
+		{token.COMMA, ","},
+		{token.STRING, "trueish"},
+		{token.COMMA, ","},
+		{token.STRING, "null"},
+		{token.COMMA, ","},
+		{token.BOOLEAN, "false"},
+		{token.RBRACKET, "]"},
+		{token.EOF, ""},
+	}
+
+	l := NewLexer([]byte(`[true, null, trueish, "null", false]`))
+	for i, test := range tests {
+		tok := l.NewToken()
+		if test.typ != tok.Type || test.lit != string(tok.Lit) {
+			t.Fatalf("On test[%d], expected %s %q, Got=%s %q", i, test.typ, test.lit, tok.Type, string(tok.Lit))
+		}
+	}
+}
+
+func TestLexerErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		msg   string
+	}{
+		{`"unterminated`, "unterminated string"},
+		{`[1, /* open comment`, "unterminated block comment"},
+	}
+
+	for i, test := range tests {
+		l := NewLexer([]byte(test.input))
+		tok := l.NewToken()
+		for tok.Type != token.INVALID && tok.Type != token.EOF {
+			tok = l.NewToken()
+		}
+		if tok.Type != token.INVALID {
+			t.Fatalf("On test[%d], expected Type=%s, Got=%s", i, token.INVALID, tok.Type)
+		}
+		if err, ok := l.Err().(*SyntaxError); !ok || err.Msg != test.msg {
+			t.Fatalf("On test[%d], expected Err=%s, Got=%v", i, test.msg, l.Err())
+		}
+	}
+
+	// Truncated input must end in EOF rather than reading past the input.
+	for _, input := range []string{"", "5", "/", "/*", "tru", "// only a comment", "12 // trailing"} {
+		l := NewLexer([]byte(input))
+		for i := 0; i < 5; i++ {
+			l.PeakToken()
+			l.NewToken()
+		}
+	}
+}
+
+func TestPosition(t *testing.T) {
+	input := "// héllo\nname: \"välue\",\n\tcount: 12"
+
+	tests := []struct {
+		typ token.Type
+		pos token.Pos
+	}{
+		{token.LBRACE, token.Pos{Offset: 0, Line: 1, Column: 1}},
+		{token.STRING, token.Pos{Offset: 10, Line: 2, Column: 1}},
+		{token.COLON, token.Pos{Offset: 14, Line: 2, Column: 5}},
+		{token.STRING, token.Pos{Offset: 16, Line: 2, Column: 7}},
+		{token.COMMA, token.Pos{Offset: 24, Line: 2, Column: 14}},
+		{token.STRING, token.Pos{Offset: 27, Line: 3, Column: 2}},
+		{token.COLON, token.Pos{Offset: 32, Line: 3, Column: 7}},
+		{token.INTEGER, token.Pos{Offset: 34, Line: 3, Column: 9}},
+		{token.RBRACE, token.Pos{Offset: 36, Line: 3, Column: 11}},
+		{token.EOF, token.Pos{Offset: 36, Line: 3, Column: 11}},
+	}
+
+	l := NewLexer([]byte(input))
+	for i, test := range tests {
+		if peek := l.PeakToken(); peek.Pos != test.pos {
+			t.Fatalf("On test[%d], expected peeked Pos=%v, Got=%v", i, test.pos, peek.Pos)
+		}
+		tok := l.NewToken()
+		if test.typ != tok.Type {
+			t.Fatalf("On test[%d], expected Type=%s, Got=%s", i, test.typ, tok.Type)
+		}
+		if test.pos != tok.Pos {
+			t.Fatalf("On test[%d], expected Pos=%+v, Got=%+v", i, test.pos, tok.Pos)
+		}
+	}
+}
+
+func TestPositionInvalidUTF8(t *testing.T) {
+	// Each invalid byte decodes to U+FFFD but takes one byte of the input.
+	input := "{a: \"\xff\xfe\", b: 1}"
+
+	tests := []struct {
+		typ token.Type
+		pos token.Pos
+	}{
+		{token.LBRACE, token.Pos{Offset: 0, Line: 1, Column: 1}},
+		{token.STRING, token.Pos{Offset: 1, Line: 1, Column: 2}},
+		{token.COLON, token.Pos{Offset: 2, Line: 1, Column: 3}},
+		{token.STRING, token.Pos{Offset: 4, Line: 1, Column: 5}},
+		{token.COMMA, token.Pos{Offset: 8, Line: 1, Column: 9}},
+		{token.STRING, token.Pos{Offset: 10, Line: 1, Column: 11}},
+	}
+
+	for _, l := range []*Lexer{NewLexer([]byte(input)), NewReaderLexer(strings.NewReader(input))} {
+		for i, test := range tests {
+			tok := l.NewToken()
+			if test.typ != tok.Type || test.pos != tok.Pos {
+				t.Fatalf("On test[%d], expected %s at %+v, Got=%s at %+v", i, test.typ, test.pos, tok.Type, tok.Pos)
+			}
+		}
+	}
+}
+
+func TestSyntaxErrorString(t *testing.T) {
+	l := NewLexer([]byte("{\n\ta: 1,\n\t\"timeout\" 5\n}"))
+	err := l.Errorf(token.Pos{Offset: 18, Line: 3, Column: 12}, "expected ':' after key %q", "timeout")
+	err.File = "config.json"
+
+	expected := "config.json:3:12: expected ':' after key \"timeout\"\n\t\"timeout\" 5\n\t          ^"
+	if err.Error() != expected {
+		t.Fatalf("TestSyntaxErrorString expected=%q, Got=%q", expected, err.Error())
+	}
+}
+
+func TestNumber(t *testing.T) {
+	tests := []struct {
+		input string
+		typ   token.Type
+		lit   string
+	}{
+		{"0", token.INTEGER, "0"},
+		{"-0", token.INTEGER, "-0"},
+		{"5245243", token.INTEGER, "5245243"},
+		{"-12", token.INTEGER, "-12"},
+		{"007", token.INTEGER, "007"},
+		{"123.5", token.FLOAT, "123.5"},
+		{"-1.5e10", token.FLOAT, "-1.5e10"},
+		{"1E+2", token.FLOAT, "1E+2"},
+		{"2e-3", token.FLOAT, "2e-3"},
+		{"1.2.3", token.STRING, "1.2.3"},
+		{"2022-01-01", token.STRING, "2022-01-01"},
+		{"10MB", token.STRING, "10MB"},
+		{"-abc", token.STRING, "-abc"},
+		{"٣", token.STRING, "٣"},
+		{"1.", token.INVALID, "1."},
+		{"2e", token.INVALID, "2e"},
+		{"3e+", token.INVALID, "3e+"},
+		{"-", token.INVALID, "-"},
+	}
+
+	for i, test := range tests {
+		l := NewLexer([]byte(test.input))
+		tok := l.NewToken()
+		if test.typ != tok.Type || test.lit != string(tok.Lit) {
+			t.Fatalf("On test[%d], expected %s %q, Got=%s %q", i, test.typ, test.lit, tok.Type, string(tok.Lit))
+		}
+	}
+
+	l := NewLexer([]byte("[1, 2,\n  3.]"))
+	for tok := l.NewToken(); tok.Type != token.INVALID; tok = l.NewToken() {
+	}
+	if err, ok := l.Err().(*SyntaxError); !ok || err.Msg != `invalid number "3."` || err.Pos.String() != "2:3" {
+		t.Fatalf("TestNumber expected invalid number at 2:3, Got=%v", l.Err())
+	}
+}
+
+func TestStrictLexer(t *testing.T) {
+	tests := []struct {
+		input string
+		msg   string
+	}{
+		{"01", `invalid number "01": leading zero`},
+		{"-012.5", `invalid number "-012": leading zero`},
+		{"1.2.3", `invalid number "1.2.3"`},
+		{"name", `unquoted string "name"`},
+		{"// comment\n1", "comments are not allowed"},
+	}
+
+	for i, test := range tests {
+		l := NewStrictLexer([]byte(test.input))
+		if tok := l.NewToken(); tok.Type != token.INVALID {
+			t.Fatalf("On test[%d], expected Type=%s, Got=%s", i, token.INVALID, tok.Type)
+		}
+		if err, ok := l.Err().(*SyntaxError); !ok || err.Msg != test.msg {
+			t.Fatalf("On test[%d], expected Err=%s, Got=%v", i, test.msg, l.Err())
+		}
+	}
+
+	l := NewStrictLexer([]byte(`"name":"value"`))
+	if tok := l.NewToken(); tok.Type != token.STRING {
+		t.Fatalf("TestStrictLexer expected no outer brace, Got=%s", tok.Type)
+	}
+}
+
+func TestStringEscapes(t *testing.T) {
+	tests := []struct {
+		input string
+		lit   string
+	}{
+		{`"say \"hi\""`, `say "hi"`},
+		{`"a\nb\tc\\d\/e"`, "a\nb\tc\\d/e"},
+		{`"\b\f\r"`, "\b\f\r"},
+		{`"café"`, "café"},
+		{`"😀"`, "😀"},
+		{`"\ud83d x"`, "� x"},
+		{`"\ude00\ud83d"`, "��"},
+		{`"ends with \\"`, `ends with \`},
+		{`"é raw"`, "é raw"},
+	}
+
+	for i, test := range tests {
+		l := NewLexer([]byte(test.input))
+		tok := l.NewToken()
+		if tok.Type != token.STRING || string(tok.Lit) != test.lit {
+			t.Fatalf("On test[%d], expected STRING %q, Got=%s %q (%v)", i, test.lit, tok.Type, string(tok.Lit), l.Err())
+		}
+		if tok = l.NewToken(); tok.Type != token.EOF {
+			t.Fatalf("On test[%d], expected EOF, Got=%s %q", i, tok.Type, string(tok.Lit))
+		}
+	}
+
+	errors := []struct {
+		input string
+		msg   string
+	}{
+		{`"bad \q"`, `invalid escape sequence "\\q"`},
+		{`"bad \u12g4"`, `invalid unicode escape "\\u12g"`},
+		{`"open \"`, "unterminated string"},
+	}
+
+	for i, test := range errors {
+		l := NewLexer([]byte(test.input))
+		if tok := l.NewToken(); tok.Type != token.INVALID {
+			t.Fatalf("On test[%d], expected Type=%s, Got=%s", i, token.INVALID, tok.Type)
+		}
+		if err, ok := l.Err().(*SyntaxError); !ok || err.Msg != test.msg {
+			t.Fatalf("On test[%d], expected Err=%s, Got=%v", i, test.msg, l.Err())
+		}
+	}
+}
+
+func TestReaderLexer(t *testing.T) {
+	inputs := []string{
+		"{\"a\": [1, -2.5e3, true, null, \"x\\u00e9\\ud83d\\ude00\"]} // c\n/* d */ {b: c}\n",
+		"1 2\n3",
+		"\"unterminated",
+		"[1, 2, ",
+	}
+
+	for i, input := range inputs {
+		want := NewLexer([]byte(input))
+		want.openBrace, want.closeBrace = false, false
+		l := NewReaderLexer(iotest.OneByteReader(strings.NewReader(input)))
+
+		for j := 0; ; j++ {
+			if peek, expected := l.PeakToken(), want.PeakToken(); !reflect.DeepEqual(peek, expected) {
+				t.Fatalf("On test[%d][%d], expected peeked %+v, Got=%+v", i, j, expected, peek)
+			}
+			tok, expected := l.NewToken(), want.NewToken()
+			if !reflect.DeepEqual(tok, expected) {
+				t.Fatalf("On test[%d][%d], expected %+v, Got=%+v", i, j, expected, tok)
+			}
+			if tok.Type == token.EOF || tok.Type == token.INVALID {
+				if (l.Err() == nil) != (want.Err() == nil) || l.Err() != nil && l.Err().Error() != want.Err().Error() {
+					t.Fatalf("On test[%d], expected error %v, Got=%v", i, want.Err(), l.Err())
+				}
+				break
+			}
+		}
+	}
+
+	// Consumed input is discarded, and positions keep counting.
+	l := NewReaderLexer(strings.NewReader(strings.Repeat("{\"key\": \"value\"}\n", 20000)))
+	var last token.Token
+	for tok := l.NewToken(); tok.Type != token.EOF; tok = l.NewToken() {
+		last = tok
+	}
+	if len(l.input) > discardSize+1024 {
+		t.Fatalf("TestReaderLexer expected at most %d runes kept, Got=%d", discardSize+1024, len(l.input))
+	}
+	if want := (token.Pos{Offset: 20000*17 - 2, Line: 20000, Column: 16}); last.Pos != want {
+		t.Fatalf("TestReaderLexer expected last Pos=%+v, Got=%+v", want, last.Pos)
+	}
+
+	l = NewReaderLexer(iotest.ErrReader(io.ErrUnexpectedEOF))
+	if tok := l.NewToken(); tok.Type != token.INVALID || l.Err() != io.ErrUnexpectedEOF {
+		t.Fatalf("TestReaderLexer expected the read error, Got=%v (%v)", tok, l.Err())
+	}
+}
+
+func TestEmitComments(t *testing.T) {
+	input := "// lead\na: 1, /* b */ é: \"x\"\n"
+
+	tests := []struct {
+		typ      token.Type
+		lit      string
+		pos, end token.Pos
+	}{
+		{token.COMMENT, "// lead", token.Pos{Offset: 0, Line: 1, Column: 1}, token.Pos{Offset: 7, Line: 1, Column: 8}},
+		{token.LBRACE, "{", token.Pos{Offset: 0, Line: 1, Column: 1}, token.Pos{Offset: 0, Line: 1, Column: 1}},
+		{token.STRING, "a", token.Pos{Offset: 8, Line: 2, Column: 1}, token.Pos{Offset: 9, Line: 2, Column: 2}},
+		{token.COLON, ":", token.Pos{Offset: 9, Line: 2, Column: 2}, token.Pos{Offset: 10, Line: 2, Column: 3}},
+		{token.INTEGER, "1", token.Pos{Offset: 11, Line: 2, Column: 4}, token.Pos{Offset: 12, Line: 2, Column: 5}},
+		{token.COMMA, ",", token.Pos{Offset: 12, Line: 2, Column: 5}, token.Pos{Offset: 13, Line: 2, Column: 6}},
+		{token.COMMENT, "/* b */", token.Pos{Offset: 14, Line: 2, Column: 7}, token.Pos{Offset: 21, Line: 2, Column: 14}},
+		{token.STRING, "é", token.Pos{Offset: 22, Line: 2, Column: 15}, token.Pos{Offset: 24, Line: 2, Column: 16}},
+		{token.COLON, ":", token.Pos{Offset: 24, Line: 2, Column: 16}, token.Pos{Offset: 25, Line: 2, Column: 17}},
+		{token.STRING, "x", token.Pos{Offset: 26, Line: 2, Column: 18}, token.Pos{Offset: 29, Line: 2, Column: 21}},
+		{token.RBRACE, "}", token.Pos{Offset: 30, Line: 3, Column: 1}, token.Pos{Offset: 30, Line: 3, Column: 1}},
+		{token.EOF, "", token.Pos{Offset: 30, Line: 3, Column: 1}, token.Pos{Offset: 30, Line: 3, Column: 1}},
+	}
+
+	l := NewLexer([]byte(input))
+	l.EmitComments()
+	for i, test := range tests {
+		tok := l.NewToken()
+		if tok.Type != test.typ || string(tok.Lit) != test.lit || tok.Pos != test.pos || tok.End != test.end {
+			t.Fatalf("On test[%d], expected %s %q at %+v-%+v, Got=%s %q at %+v-%+v",
+				i, test.typ, test.lit, test.pos, test.end, tok.Type, string(tok.Lit), tok.Pos, tok.End)
+		}
+	}
+
+	l = NewLexer([]byte("a: 1 /* open"))
+	l.EmitComments()
+	for tok := l.NewToken(); tok.Type != token.INVALID; tok = l.NewToken() {
+		if tok.Type == token.EOF {
+			t.Fatalf("TestEmitComments expected an unterminated comment error")
+		}
+	}
+	if err := l.Err(); err == nil || !strings.Contains(err.Error(), "unterminated block comment") {
+		t.Fatalf("TestEmitComments expected an unterminated comment error, Got=%v", err)
+	}
+}
@@ -0,0 +1,148 @@
+package json
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/qw20012/go-json/lexer"
+	"github.com/qw20012/go-json/parser"
+)
+
+// Marshaler is implemented by types that write their own JSON. The method has
+// the signature of encoding/json, so types written for it work here as well.
+type Marshaler interface {
+	MarshalJSON() ([]byte, error)
+}
+
+// Unmarshaler is implemented by types that read their own JSON. The input is
+// always RFC 8259 JSON: a bare string such as 30s in the relaxed syntax is
+// passed quoted, as "30s".
+type Unmarshaler interface {
+	UnmarshalJSON([]byte) error
+}
+
+// MarshalerError reports an error returned by, or the invalid output of, a
+// MarshalJSON or MarshalText method.
+type MarshalerError struct {
+	Type   reflect.Type
+	Err    error
+	Method string
+}
+
+func (e *MarshalerError) Error() string {
+	return fmt.Sprintf("error calling %s for type %s: %v", e.Method, e.Type, e.Err)
+}
+
+func (e *MarshalerError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	marshalerType   = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	unmarshalerType = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+)
+
+// hook returns the value whose method set implements iface: v itself, or its
+// address when only the pointer has the method and v is addressable.
+func hook(v reflect.Value, iface reflect.Type) (reflect.Value, bool) {
+	if v.Kind() != reflect.Interface && v.Type().Implements(iface) {
+		return v, true
+	}
+	if v.Kind() != reflect.Ptr && v.CanAddr() && v.Addr().Type().Implements(iface) {
+		return v.Addr(), true
+	}
+	return v, false
+}
+
+// parseMarshaler writes value with its MarshalJSON or MarshalText method, if
+// it has one. The output of MarshalJSON is parsed and written again, keeping
+// the order of its keys, so that it follows the Indent and Human options.
+func (e *encodeState) parseMarshaler(value reflect.Value) (bool, error) {
+	if m, ok := hook(value, marshalerType); ok {
+		if m.Kind() == reflect.Ptr && m.IsNil() {
+			e.buf = append(e.buf, "null"...)
+			return true, nil
+		}
+
+		raw, err := m.Interface().(Marshaler).MarshalJSON()
+		if err != nil {
+			return true, &MarshalerError{Type: value.Type(), Err: err, Method: "MarshalJSON"}
+		}
+
+		p := parser.NewParser(lexer.NewStrictLexer(raw))
+		p.KeepOrder()
+		ast, err := p.Parse()
+		if err != nil {
+			return true, &MarshalerError{Type: value.Type(), Err: err, Method: "MarshalJSON"}
+		}
+		return true, e.marshal(reflect.ValueOf(ast))
+	}
+
+	if m, ok := hook(value, textMarshalerType); ok {
+		if m.Kind() == reflect.Ptr && m.IsNil() {
+			e.buf = append(e.buf, "null"...)
+			return true, nil
+		}
+
+		text, err := m.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return true, &MarshalerError{Type: value.Type(), Err: err, Method: "MarshalText"}
+		}
+		e.parseString(string(text))
+		return true, nil
+	}
+	return false, nil
+}
+
+// buildUnmarshaler stores ast in v with its UnmarshalJSON or UnmarshalText
+// method, if it has one. UnmarshalJSON receives ast as JSON; UnmarshalText
+// receives strings, and numbers and booleans in their literal form.
+func (d *decodeState) buildUnmarshaler(v reflect.Value, ast any) (bool, error) {
+	if u, ok := hook(v, unmarshalerType); ok {
+		if u.Kind() == reflect.Ptr && u.IsNil() {
+			return false, nil
+		}
+
+		raw := &encodeState{}
+		if err := raw.marshal(reflect.ValueOf(ast)); err != nil {
+			return true, err
+		}
+		return true, d.hookError(u.Interface().(Unmarshaler).UnmarshalJSON(raw.buf))
+	}
+
+	if u, ok := hook(v, textUnmarshalerType); ok {
+		if u.Kind() == reflect.Ptr && u.IsNil() {
+			return false, nil
+		}
+
+		var text string
+		switch realValue := ast.(type) {
+		case string:
+			text = realValue
+		case int64:
+			text = strconv.FormatInt(realValue, 10)
+		case uint64:
+			text = strconv.FormatUint(realValue, 10)
+		case float64:
+			text = strconv.FormatFloat(realValue, 'g', -1, 64)
+		case bool:
+			text = strconv.FormatBool(realValue)
+		default:
+			return true, d.typeError(ast, v.Type())
+		}
+		return true, d.hookError(u.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(text)))
+	}
+	return false, nil
+}
+
+// hookError adds the path of the value to an error returned by a custom
+// unmarshaler.
+func (d *decodeState) hookError(err error) error {
+	if err == nil || len(d.path) == 0 {
+		return err
+	}
+	return fmt.Errorf("%s: %w", strings.Join(d.path, "."), err)
+}
@@ -0,0 +1,81 @@
+package json
+
+// MergePatch applies patch to g as an RFC 7396 JSON Merge Patch: the members
+// of an object in patch replace those of g, objects are merged recursively,
+// and a null removes the member. A patch that is not an object replaces g
+// as a whole. Unlike Merge, colliding values are never combined.
+func (g *Container) MergePatch(patch *Container) error {
+	return g.mergePatch(Pointer{}, patch.Data())
+}
+
+func (g *Container) mergePatch(path Pointer, patch any) error {
+	keys, ok := objectKeys(patch)
+	if !ok {
+		_, err := g.Set(deepCopy(patch), path...)
+		return err
+	}
+
+	if target, err := g.Get(path); err != nil || !isObject(target.Data()) {
+		if _, err := g.Set(g.newObject(), path...); err != nil {
+			return err
+		}
+	}
+
+	for _, key := range keys {
+		value, _ := objectGet(patch, key)
+		child := append(append(Pointer{}, path...), key)
+		if value != nil {
+			if err := g.mergePatch(child, value); err != nil {
+				return err
+			}
+		} else if g.Exist(child...) {
+			if err := g.Delete(child...); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// CreateMergePatch returns the JSON Merge Patch that turns a into b, so that
+// a.MergePatch(CreateMergePatch(a, b)) makes a equal to b. As RFC 7396 has no
+// way to set a member to null, a null inside b is left out of the patch when
+// the member is new and removes it otherwise.
+func CreateMergePatch(a, b *Container) *Container {
+	return &Container{object: mergeDiff(a.Data(), b.Data())}
+}
+
+func mergeDiff(a, b any) any {
+	keys, ok := objectKeys(a)
+	newKeys, ok2 := objectKeys(b)
+	if !ok || !ok2 {
+		return deepCopy(b)
+	}
+
+	var patch any = map[string]any{}
+	if _, ordered := b.(*Object); ordered {
+		patch = NewObject()
+	}
+	for _, key := range keys {
+		if _, ok := objectGet(b, key); !ok {
+			objectSet(patch, key, nil)
+		}
+	}
+	for _, key := range newKeys {
+		newValue, _ := objectGet(b, key)
+		oldValue, ok := objectGet(a, key)
+		switch {
+		case !ok:
+			if newValue != nil {
+				objectSet(patch, key, deepCopy(newValue))
+			}
+		case newValue == nil:
+			if oldValue != nil {
+				objectSet(patch, key, nil)
+			}
+		case !equalValues(oldValue, newValue):
+			objectSet(patch, key, mergeDiff(oldValue, newValue))
+		}
+	}
+	return patch
+}
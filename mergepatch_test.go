@@ -0,0 +1,75 @@
+package json
+
+import "testing"
+
+func TestMergePatch(t *testing.T) {
+	// Examples from RFC 7396, appendix A.
+	tests := []struct {
+		target, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, test := range tests {
+		c := Parse(test.target)
+		if err := c.MergePatch(Parse(test.patch)); err != nil {
+			t.Errorf("MergePatch(%s, %s): %v", test.target, test.patch, err)
+			continue
+		}
+		if got := Marshal(c.Data()); got != test.want {
+			t.Errorf("MergePatch(%s, %s) = %s, want %s", test.target, test.patch, got, test.want)
+		}
+	}
+}
+
+func TestMergePatchDocument(t *testing.T) {
+	doc, err := ParseDocument("// base\nport: 80,\nlog: {level: info, file: a.log},\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.MergePatch(Parse(`{"log":{"level":"debug","file":null}}`)); err != nil {
+		t.Fatal(err)
+	}
+	if want := "// base\nport: 80,\nlog: {level: \"debug\"},\n"; doc.Source() != want {
+		t.Errorf("Source() = %q, want %q", doc.Source(), want)
+	}
+}
+
+func TestCreateMergePatch(t *testing.T) {
+	tests := []struct {
+		a, b, want string
+		exact      bool // the patch turns a into b
+	}{
+		{`{"a":1,"b":{"c":2,"d":3},"e":[1]}`, `{"a":1,"b":{"c":4},"e":[1,2],"f":true}`, `{"b":{"c":4,"d":null},"e":[1,2],"f":true}`, true},
+		{`{"a":1}`, `{"a":1}`, `{}`, true},
+		{`{"a":1}`, `{"a":null}`, `{"a":null}`, false},
+		{`{"a":{"b":1}}`, `{"a":[1]}`, `{"a":[1]}`, true},
+		{`[1]`, `{"a":1}`, `{"a":1}`, true},
+	}
+	for _, test := range tests {
+		a, b := Parse(test.a), Parse(test.b)
+		patch := CreateMergePatch(a, b)
+		if got := patch.String(); got != test.want {
+			t.Errorf("CreateMergePatch(%s, %s) = %s, want %s", test.a, test.b, got, test.want)
+		}
+		if err := a.MergePatch(patch); err != nil {
+			t.Fatal(err)
+		}
+		if test.exact && a.String() != b.String() {
+			t.Errorf("MergePatch(CreateMergePatch(%s, %s)) = %s", test.a, test.b, a.String())
+		}
+	}
+}
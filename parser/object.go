@@ -0,0 +1,66 @@
+package parser
+
+// Object is an object that keeps its keys in the order they were first set.
+// The parser returns objects as *Object instead of map[string]any when
+// KeepOrder is set.
+type Object struct {
+	keys   []string
+	values map[string]any
+}
+
+// NewObject returns an empty object.
+func NewObject() *Object {
+	return &Object{values: map[string]any{}}
+}
+
+// Len returns the number of keys.
+func (o *Object) Len() int {
+	return len(o.keys)
+}
+
+// Keys returns the keys in order.
+func (o *Object) Keys() []string {
+	return append([]string(nil), o.keys...)
+}
+
+// Get returns the value of key and whether it is present.
+func (o *Object) Get(key string) (any, bool) {
+	value, ok := o.values[key]
+	return value, ok
+}
+
+// Set sets the value of key. A new key goes after the others; an existing one
+// keeps its place.
+func (o *Object) Set(key string, value any) {
+	if o.values == nil {
+		o.values = map[string]any{}
+	}
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+// Delete removes key and reports whether it was present.
+func (o *Object) Delete(key string) bool {
+	if _, ok := o.values[key]; !ok {
+		return false
+	}
+	delete(o.values, key)
+	for i, k := range o.keys {
+		if k == key {
+			o.keys = append(o.keys[:i], o.keys[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// Map returns the entries as a map, losing their order.
+func (o *Object) Map() map[string]any {
+	m := make(map[string]any, len(o.keys))
+	for key, value := range o.values {
+		m[key] = value
+	}
+	return m
+}
@@ -2,109 +2,403 @@ package parser
 
 import (
 	"fmt"
+	"io"
+	"strconv"
 
 	"github.com/qw20012/go-json/lexer"
 	"github.com/qw20012/go-json/token"
 )
 
 type Parser struct {
-	Lexer *lexer.Lexer
+	Lexer      *lexer.Lexer
+	ordered    bool // objects are *Object
+	duplicates DuplicatePolicy
+	warn       func(DuplicateKey)
+}
+
+// DuplicatePolicy says what the parser does with a key that appears more than
+// once in an object.
+type DuplicatePolicy int
+
+const (
+	KeepLast          DuplicatePolicy = iota // the last value wins, the default
+	KeepFirst                                // the first value wins
+	RejectDuplicates                         // a *lexer.SyntaxError naming both positions
+	WarnDuplicates                           // the last value wins after a call to the warn function
+	CollectDuplicates                        // the values are collected into an array, in order
+)
+
+// DuplicateKey describes a key repeated in an object.
+type DuplicateKey struct {
+	Key    string
+	First  token.Pos // position of the first occurrence
+	Second token.Pos // position of the repetition
 }
 
 func NewParser(l *lexer.Lexer) *Parser {
 	return &Parser{Lexer: l}
 }
 
-func (p *Parser) Parse() any {
+// DuplicateKeys sets the policy for keys repeated in an object. warn is called
+// for every repetition under WarnDuplicates and ignored otherwise.
+func (p *Parser) DuplicateKeys(policy DuplicatePolicy, warn func(DuplicateKey)) {
+	p.duplicates, p.warn = policy, warn
+}
+
+// Duplicates returns the policy for keys repeated in an object.
+func (p *Parser) Duplicates() DuplicatePolicy {
+	return p.duplicates
+}
+
+// KeepOrder makes the parser return objects as *Object, which keeps the keys
+// in the order of the input, instead of map[string]any.
+func (p *Parser) KeepOrder() {
+	p.ordered = true
+}
+
+// Parse reads a single value from the lexer. Malformed input is reported as a
+// *lexer.SyntaxError; an empty input yields a nil value unless the lexer is
+// strict.
+func (p *Parser) Parse() (any, error) {
 	tok := p.Lexer.NewToken()
+	if tok.Type == token.EOF && !p.Lexer.Strict() {
+		return nil, nil
+	}
+
+	value, err := parseValue(p, tok)
+	if err != nil {
+		return nil, err
+	}
+
+	if tok = p.Lexer.NewToken(); tok.Type != token.EOF {
+		return nil, p.Expected("end of input", tok)
+	}
+	return value, nil
+}
+
+// ParseValue reads the next value from the lexer and leaves the input after
+// it unread, so that a stream of values can be parsed one at a time. It
+// returns io.EOF when there are no more values.
+func (p *Parser) ParseValue() (any, error) {
+	tok := p.Lexer.NewToken()
+	if tok.Type == token.EOF {
+		return nil, io.EOF
+	}
+	return parseValue(p, tok)
+}
+
+// ParseToken converts tok into a value, reading the rest of the object or
+// array when tok opens one.
+func (p *Parser) ParseToken(tok token.Token) (any, error) {
+	return parseValue(p, tok)
+}
+
+// IsKey reports whether tok may be an object key. Besides strings, the
+// relaxed syntax accepts numbers and keywords, keeping their literal text.
+func IsKey(tok token.Token) bool {
+	switch tok.Type {
+	case token.STRING, token.INTEGER, token.FLOAT, token.BOOLEAN, token.NULL:
+		return true
+	}
+	return false
+}
+
+// parseValue converts tok, and the tokens following it for an object or an
+// array, into a value. Scalars keep the type of the literal: a string for
+// quoted and unquoted text, an int64, uint64 or float64 for a number, a bool
+// for true and false, and nil for null. A quoted "true" stays a string.
+func parseValue(p *Parser, tok token.Token) (any, error) {
 	switch tok.Type {
 	case token.STRING:
-		return string(tok.Lit)
+		return string(tok.Lit), nil
 	case token.INTEGER:
-		return string(tok.Lit)
+		return parseInteger(p, tok)
+	case token.FLOAT:
+		if f, err := strconv.ParseFloat(string(tok.Lit), 64); err == nil {
+			return f, nil
+		}
+		return nil, p.Lexer.Errorf(tok.Pos, "number %s out of range", string(tok.Lit))
 	case token.BOOLEAN:
-		return string(tok.Lit)
+		return string(tok.Lit) == "true", nil
+	case token.NULL:
+		return nil, nil
 	case token.LBRACE:
 		return parseObject(p)
 	case token.LBRACKET:
 		return parseArray(p)
-	case token.EOF:
-		return nil
 	}
-	return nil
+	return nil, p.Expected("value", tok)
 }
 
-func parseArray(p *Parser) any {
+// parseInteger returns an integer literal as int64, or as uint64 when only
+// that fits, falling back to float64 for larger values.
+func parseInteger(p *Parser, tok token.Token) (any, error) {
+	if i, err := strconv.ParseInt(string(tok.Lit), 10, 64); err == nil {
+		return i, nil
+	}
+	if u, err := strconv.ParseUint(string(tok.Lit), 10, 64); err == nil {
+		return u, nil
+	}
+	if f, err := strconv.ParseFloat(string(tok.Lit), 64); err == nil {
+		return f, nil
+	}
+	return nil, p.Lexer.Errorf(tok.Pos, "invalid number %q", string(tok.Lit))
+}
+
+func parseArray(p *Parser) (any, error) {
 	array := []any{}
-	tok := p.Lexer.PeakToken()
 
-	if tok.Type == token.RBRACKET {
-		return array
-	} else {
-		array = append(array, p.Parse())
-		tok = p.Lexer.NewToken()
+	for {
+		tok := p.Lexer.NewToken()
+
+		// Allow array end with ","
 		if tok.Type == token.RBRACKET {
-			return array
+			if len(array) > 0 && p.Lexer.Strict() {
+				return nil, p.Lexer.Errorf(tok.Pos, "trailing comma before ']'")
+			}
+			return array, nil
 		}
-	}
 
-	for {
-		array = append(array, p.Parse())
+		value, err := parseValue(p, tok)
+		if err != nil {
+			return nil, err
+		}
+		array = append(array, value)
+
 		tok = p.Lexer.NewToken()
 		if tok.Type == token.RBRACKET {
-			break
+			return array, nil
 		}
 
 		if tok.Type != token.COMMA {
-			panic(fmt.Sprintf("was expecting ',' got %s in array parse", string(tok.Lit)))
+			return nil, p.Expected("',' or ']' after array element", tok)
 		}
 	}
-
-	return array
 }
 
-func parseObject(p *Parser) any {
-	object := map[string]any{}
-	tok := p.Lexer.NewToken()
-
-	if tok.Type == token.RBRACE { // nothing inside
-		return object
-	} else {
-		key := string(tok.Lit)
-		p.Lexer.NewToken() // ':'
-		object[key] = p.Parse()
-		tok = p.Lexer.NewToken()
-		if tok.Type == token.RBRACE {
-			return object
-		}
+func parseObject(p *Parser) (any, error) {
+	var object any = map[string]any{}
+	if p.ordered {
+		object = NewObject()
+	}
+	var seen map[string]token.Pos
+	var collected map[string]bool
+	if p.duplicates != KeepLast {
+		seen = map[string]token.Pos{}
 	}
 
-	for {
-		tok = p.Lexer.NewToken()
+	for size := 0; ; size++ {
+		tok := p.Lexer.NewToken()
 
 		// Allow json last line end with ","
 		if tok.Type == token.RBRACE {
-			break
+			if size > 0 && p.Lexer.Strict() {
+				return nil, p.Lexer.Errorf(tok.Pos, "trailing comma before '}'")
+			}
+			return object, nil
 		}
 
-		key := string(tok.Lit)
+		if !IsKey(tok) {
+			return nil, p.Expected("object key", tok)
+		}
+		key, keyPos := string(tok.Lit), tok.Pos
 
 		tok = p.Lexer.NewToken() // ':'
 		if tok.Type != token.COLON {
-			panic(fmt.Sprintf("was expecting ':' got %s", string(tok.Lit)))
+			return nil, p.Expected(fmt.Sprintf("':' after key %q", key), tok)
 		}
 
-		object[key] = p.Parse()
-		tok = p.Lexer.NewToken() // ','
+		value, err := parseValue(p, p.Lexer.NewToken())
+		if err != nil {
+			return nil, err
+		}
+
+		// A repeated key is handled as the duplicate policy says.
+		first, repeated := seen[key]
+		switch {
+		case !repeated:
+			if seen != nil {
+				seen[key] = keyPos
+			}
+			setKey(object, key, value)
+		case p.duplicates == KeepFirst:
+		case p.duplicates == RejectDuplicates:
+			return nil, p.Lexer.Errorf(keyPos, "duplicate key %q, first at %s", key, first)
+		case p.duplicates == WarnDuplicates:
+			if p.warn != nil {
+				p.warn(DuplicateKey{Key: key, First: first, Second: keyPos})
+			}
+			setKey(object, key, value)
+		case p.duplicates == CollectDuplicates:
+			if collected[key] {
+				value = append(getKey(object, key).([]any), value)
+			} else {
+				if collected == nil {
+					collected = map[string]bool{}
+				}
+				collected[key] = true
+				value = []any{getKey(object, key), value}
+			}
+			setKey(object, key, value)
+		}
 
+		tok = p.Lexer.NewToken() // ','
 		if tok.Type == token.RBRACE {
-			break
+			return object, nil
 		}
 
 		if tok.Type != token.COMMA {
-			panic(fmt.Sprintf("was expecting ',' got %s", string(tok.Lit)))
+			return nil, p.Expected(fmt.Sprintf("',' or '}' after value of key %q", key), tok)
 		}
 	}
+}
+
+// setKey sets key in a map[string]any or an *Object.
+func setKey(object any, key string, value any) {
+	if ordered, ok := object.(*Object); ok {
+		ordered.Set(key, value)
+	} else {
+		object.(map[string]any)[key] = value
+	}
+}
 
-	return object
+// getKey returns the value of key in a map[string]any or an *Object.
+func getKey(object any, key string) any {
+	if ordered, ok := object.(*Object); ok {
+		value, _ := ordered.Get(key)
+		return value
+	}
+	return object.(map[string]any)[key]
+}
+
+// Expected builds the error for an unexpected token. An INVALID token carries
+// the lexer's own error instead.
+func (p *Parser) Expected(what string, tok token.Token) error {
+	if tok.Type == token.INVALID {
+		if err := p.Lexer.Err(); err != nil {
+			return err
+		}
+	}
+
+	got := strconv.Quote(string(tok.Lit))
+	if tok.Type == token.EOF {
+		got = "end of input"
+	}
+	return p.Lexer.Errorf(tok.Pos, "expected %s, got %s", what, got)
+}
+
+// Node is a value in the concrete syntax tree built by ParseDocument. It
+// records where each value, key and comma lies in the input, so that the
+// input can be edited in place: whitespace, comments and the spelling of
+// every literal stay in the text between and inside the spans.
+type Node struct {
+	Type    token.Type // LBRACE, LBRACKET, or the type of a scalar token
+	Start   int        // byte offset of the value
+	End     int        // byte offset just after the value
+	Implied bool       // an object whose outer braces are omitted; it spans the input
+	Entries []*Entry   // members of an object or elements of an array
+}
+
+// Entry is a member of an object or an element of an array.
+type Entry struct {
+	Key      string // key of an object member
+	KeyStart int    // byte span of the key as written, -1 for array elements
+	KeyEnd   int
+	Value    *Node
+	Comma    int // byte offset of the comma after the entry, or -1
+}
+
+// Start returns the byte offset where the entry begins: its key, or its value
+// for an array element.
+func (e *Entry) Start() int {
+	if e.KeyStart >= 0 {
+		return e.KeyStart
+	}
+	return e.Value.Start
+}
+
+// ParseDocument reads a single value from the lexer, like Parse, and returns
+// its concrete syntax tree.
+func (p *Parser) ParseDocument() (*Node, error) {
+	tok := p.Lexer.NewToken()
+	if tok.Type == token.EOF && !p.Lexer.Strict() {
+		return &Node{Type: token.LBRACE, Start: 0, End: tok.Pos.Offset, Implied: true}, nil
+	}
+
+	node, err := parseNode(p, tok)
+	if err != nil {
+		return nil, err
+	}
+
+	if tok = p.Lexer.NewToken(); tok.Type != token.EOF {
+		return nil, p.Expected("end of input", tok)
+	}
+	return node, nil
+}
+
+func parseNode(p *Parser, tok token.Token) (*Node, error) {
+	node := &Node{Type: tok.Type, Start: tok.Pos.Offset, End: tok.End.Offset}
+
+	switch tok.Type {
+	case token.LBRACE, token.LBRACKET:
+		node.Implied = tok.End.Offset == tok.Pos.Offset
+		closeType := token.Type(token.RBRACKET)
+		if tok.Type == token.LBRACE {
+			closeType = token.RBRACE
+		}
+
+		for {
+			tok = p.Lexer.NewToken()
+			if tok.Type == closeType {
+				if len(node.Entries) > 0 && p.Lexer.Strict() {
+					return nil, p.Lexer.Errorf(tok.Pos, "trailing comma before '%s'", string(tok.Lit))
+				}
+				break
+			}
+
+			entry := &Entry{KeyStart: -1, KeyEnd: -1, Comma: -1}
+			if node.Type == token.LBRACE {
+				if !IsKey(tok) {
+					return nil, p.Expected("object key", tok)
+				}
+				entry.Key, entry.KeyStart, entry.KeyEnd = string(tok.Lit), tok.Pos.Offset, tok.End.Offset
+
+				if tok = p.Lexer.NewToken(); tok.Type != token.COLON {
+					return nil, p.Expected(fmt.Sprintf("':' after key %q", entry.Key), tok)
+				}
+				tok = p.Lexer.NewToken()
+			}
+
+			value, err := parseNode(p, tok)
+			if err != nil {
+				return nil, err
+			}
+			entry.Value = value
+			node.Entries = append(node.Entries, entry)
+
+			tok = p.Lexer.NewToken()
+			if tok.Type == closeType {
+				break
+			}
+			if tok.Type != token.COMMA {
+				if node.Type == token.LBRACE {
+					return nil, p.Expected(fmt.Sprintf("',' or '}' after value of key %q", entry.Key), tok)
+				}
+				return nil, p.Expected("',' or ']' after array element", tok)
+			}
+			entry.Comma = tok.Pos.Offset
+		}
+		node.End = tok.End.Offset
+		if node.Implied {
+			node.Start, node.End = 0, tok.Pos.Offset
+		}
+		return node, nil
+	case token.STRING, token.INTEGER, token.FLOAT, token.BOOLEAN, token.NULL:
+		// Check the value as parseValue would.
+		if _, err := parseValue(p, tok); err != nil {
+			return nil, err
+		}
+		return node, nil
+	}
+	return nil, p.Expected("value", tok)
 }
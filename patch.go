@@ -0,0 +1,349 @@
+package json
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrTestFailed is returned by ApplyPatch when a test operation finds a
+// different value.
+var ErrTestFailed = errors.New("test operation failed")
+
+// Operation is an operation of a JSON Patch, RFC 6902. Op is one of "add",
+// "remove", "replace", "move", "copy" and "test"; Path and From are JSON
+// Pointers in their string form. Value is used by add, replace and test only.
+type Operation struct {
+	Op    string
+	Path  string
+	From  string
+	Value any
+}
+
+// MarshalJSON writes the members the operation uses, value included even
+// when it is null.
+func (op Operation) MarshalJSON() ([]byte, error) {
+	var b strings.Builder
+	b.WriteString(`{"op":` + quote(op.Op, false) + `,"path":` + quote(op.Path, false))
+	switch op.Op {
+	case "move", "copy":
+		b.WriteString(`,"from":` + quote(op.From, false))
+	case "add", "replace", "test":
+		value, err := MarshalE(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		b.WriteString(`,"value":` + value)
+	}
+	b.WriteString("}")
+	return []byte(b.String()), nil
+}
+
+// Patch is a JSON Patch document: operations applied in order.
+type Patch []Operation
+
+// ParsePatch parses a JSON Patch document.
+func ParsePatch(jsonStr string) (Patch, error) {
+	c, err := ParseE(jsonStr)
+	if err != nil {
+		return nil, err
+	}
+
+	items, ok := c.Data().([]any)
+	if !ok {
+		return nil, errors.New("patch is not an array")
+	}
+	patch := make(Patch, len(items))
+	for i, item := range items {
+		object, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("patch operation %d is not an object", i)
+		}
+
+		op := &patch[i]
+		for _, member := range []struct {
+			name   string
+			target *string
+			needed bool
+		}{
+			{"op", &op.Op, true},
+			{"path", &op.Path, true},
+			{"from", &op.From, false},
+		} {
+			value, ok := object[member.name].(string)
+			if !ok && (member.needed || object[member.name] != nil) {
+				return nil, fmt.Errorf("patch operation %d: %q must be a string", i, member.name)
+			}
+			*member.target = value
+		}
+
+		value, hasValue := object["value"]
+		switch op.Op {
+		case "add", "replace", "test":
+			if !hasValue {
+				return nil, fmt.Errorf("patch operation %d: %s without a value", i, op.Op)
+			}
+			op.Value = value
+		case "move", "copy":
+			if _, ok := object["from"]; !ok {
+				return nil, fmt.Errorf("patch operation %d: %s without from", i, op.Op)
+			}
+		case "remove":
+		default:
+			return nil, fmt.Errorf("patch operation %d: unknown op %q", i, op.Op)
+		}
+	}
+	return patch, nil
+}
+
+// ApplyPatch applies the operations of patch in order. It is atomic: when an
+// operation fails, the data, and the source of a document, are restored as
+// they were and the error names the failed operation.
+func (g *Container) ApplyPatch(patch Patch) error {
+	// A Container inside a document is patched through the document, which
+	// is restored as a whole.
+	state := g
+	if g.root != nil {
+		state = g.root
+	}
+	saved := deepCopy(state.object)
+	var savedDoc document
+	if state.doc != nil {
+		savedDoc = *state.doc
+	}
+
+	for i, op := range patch {
+		if err := g.applyOperation(op); err != nil {
+			state.object = saved
+			if state.doc != nil {
+				*state.doc = savedDoc
+			}
+			if g.root != nil {
+				g.refresh()
+			}
+			return fmt.Errorf("patch operation %d (%s %q): %w", i, op.Op, op.Path, err)
+		}
+	}
+	return nil
+}
+
+func (g *Container) applyOperation(op Operation) error {
+	path, err := ParsePointer(op.Path)
+	if err != nil {
+		return err
+	}
+
+	switch op.Op {
+	case "add":
+		return g.patchAdd(path, deepCopy(op.Value))
+	case "remove":
+		return g.patchRemove(path)
+	case "replace":
+		if _, err := g.Get(path); err != nil {
+			return err
+		}
+		_, err := g.Set(deepCopy(op.Value), path...)
+		return err
+	case "test":
+		value, err := g.Get(path)
+		if err != nil {
+			return err
+		}
+		if !equalValues(value.Data(), op.Value) {
+			return ErrTestFailed
+		}
+		return nil
+	case "move", "copy":
+		from, err := ParsePointer(op.From)
+		if err != nil {
+			return err
+		}
+		value, err := g.Get(from)
+		if err != nil {
+			return err
+		}
+		if op.Op == "copy" {
+			return g.patchAdd(path, deepCopy(value.Data()))
+		}
+
+		if len(path) > len(from) && path[:len(from)].String() == from.String() {
+			return fmt.Errorf("cannot move %q into itself", op.From)
+		}
+		if err := g.patchRemove(from); err != nil {
+			return err
+		}
+		return g.patchAdd(path, value.Data())
+	}
+	return fmt.Errorf("unknown op %q", op.Op)
+}
+
+// patchAdd adds value at path: into an existing object, or into an array,
+// where it is inserted before the element at the index or appended for "-".
+func (g *Container) patchAdd(path Pointer, value any) error {
+	if len(path) == 0 {
+		_, err := g.Set(value)
+		return err
+	}
+
+	parentPath, last := path[:len(path)-1], path[len(path)-1]
+	parent, err := g.Get(parentPath)
+	if err != nil {
+		return err
+	}
+
+	array, ok := parent.Data().([]any)
+	if !ok {
+		if !isObject(parent.Data()) {
+			return fmt.Errorf("failed to resolve '%v': %w", parentPath, ErrNotObjOrArray)
+		}
+		_, err = g.Set(value, path...)
+		return err
+	}
+
+	if last == "-" {
+		if len(parentPath) > 0 {
+			_, err = g.Set(value, path...)
+			return err
+		}
+		last = fmt.Sprint(len(array))
+	}
+	index, err := arrayIndex(last)
+	if err != nil || index > len(array) {
+		return fmt.Errorf("failed to resolve '%v': %w", path, ErrOutOfBounds)
+	}
+
+	inserted := make([]any, 0, len(array)+1)
+	inserted = append(append(append(inserted, array[:index]...), value), array[index:]...)
+	_, err = g.Set(inserted, parentPath...)
+	return err
+}
+
+// patchRemove removes the value at path, which must exist.
+func (g *Container) patchRemove(path Pointer) error {
+	if len(path) == 0 {
+		return errors.New("cannot remove the whole document")
+	}
+	if _, err := g.Get(path); err != nil {
+		return err
+	}
+	if len(path) > 1 || isObject(g.object) {
+		return g.Delete(path...)
+	}
+
+	// An element of a top level array.
+	array := g.object.([]any)
+	index, _ := arrayIndex(path[0])
+	_, err := g.Set(append(append([]any{}, array[:index]...), array[index+1:]...))
+	return err
+}
+
+// Diff returns a JSON Patch that turns a into b: removes and adds for object
+// members, and operations element by element for arrays, with the surplus
+// removed from or added to the end.
+func Diff(a, b *Container) Patch {
+	patch := Patch{}
+	d := &differ{
+		arrays: matchByPosition,
+		removed: func(path Pointer, _ any) {
+			patch = append(patch, Operation{Op: "remove", Path: path.String()})
+		},
+		added: func(path Pointer, value any) {
+			patch = append(patch, Operation{Op: "add", Path: path.String(), Value: deepCopy(value)})
+		},
+		changed: func(path Pointer, _, value any) {
+			patch = append(patch, Operation{Op: "replace", Path: path.String(), Value: deepCopy(value)})
+		},
+	}
+	d.walk(Pointer{}, a.Data(), b.Data())
+	return patch
+}
+
+// differ walks two values side by side for Diff and Compare. Members of
+// objects are matched by name and elements of arrays by the arrays strategy,
+// which calls walk for the pairs it matches. The differences go to removed,
+// added and changed.
+type differ struct {
+	arrays  func(d *differ, path Pointer, array, newArray []any)
+	removed func(path Pointer, old any)
+	added   func(path Pointer, value any)
+	changed func(path Pointer, old, value any)
+}
+
+func (d *differ) walk(path Pointer, a, b any) {
+	if keys, ok := objectKeys(a); ok {
+		if newKeys, ok := objectKeys(b); ok {
+			for _, key := range keys {
+				oldValue, _ := objectGet(a, key)
+				if newValue, ok := objectGet(b, key); ok {
+					d.walk(pointerTo(path, key), oldValue, newValue)
+				} else {
+					d.removed(pointerTo(path, key), oldValue)
+				}
+			}
+			for _, key := range newKeys {
+				if _, ok := objectGet(a, key); !ok {
+					newValue, _ := objectGet(b, key)
+					d.added(pointerTo(path, key), newValue)
+				}
+			}
+			return
+		}
+	}
+
+	if array, ok := a.([]any); ok {
+		if newArray, ok := b.([]any); ok {
+			d.arrays(d, path, array, newArray)
+			return
+		}
+	}
+
+	if !equalValues(a, b) {
+		d.changed(path, a, b)
+	}
+}
+
+// pointerTo returns the pointer to a member or element of the value at path.
+func pointerTo(path Pointer, token any) Pointer {
+	return append(append(Pointer{}, path...), fmt.Sprint(token))
+}
+
+// matchByPosition matches the elements of arrays index by index. The surplus
+// is removed from the end, last first, or appended with '-'.
+func matchByPosition(d *differ, path Pointer, array, newArray []any) {
+	i := 0
+	for ; i < len(array) && i < len(newArray); i++ {
+		d.walk(pointerTo(path, i), array[i], newArray[i])
+	}
+	for j := len(array) - 1; j >= i; j-- {
+		d.removed(pointerTo(path, j), array[j])
+	}
+	for ; i < len(newArray); i++ {
+		d.added(pointerTo(path, "-"), newArray[i])
+	}
+}
+
+// deepCopy copies the objects and arrays inside value.
+func deepCopy(value any) any {
+	switch t := value.(type) {
+	case map[string]any:
+		copied := make(map[string]any, len(t))
+		for key, v := range t {
+			copied[key] = deepCopy(v)
+		}
+		return copied
+	case *Object:
+		copied := NewObject()
+		for _, key := range t.Keys() {
+			v, _ := t.Get(key)
+			copied.Set(key, deepCopy(v))
+		}
+		return copied
+	case []any:
+		copied := make([]any, len(t))
+		for i, v := range t {
+			copied[i] = deepCopy(v)
+		}
+		return copied
+	}
+	return value
+}
@@ -0,0 +1,136 @@
+package json
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestApplyPatch(t *testing.T) {
+	// Examples from RFC 6902, appendix A.
+	tests := []struct {
+		doc, patch, want string
+	}{
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`},
+		{`{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+		{`{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+		{`{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{`{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`},
+		{`{"baz":"qux","foo":["a",2,"c"]}`, `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2.0}]`,
+			`{"baz":"qux","foo":["a",2,"c"]}`},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`, `{"child":{"grandchild":{}},"foo":"bar"}`},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`},
+		{`{"foo":null}`, `[{"op":"test","path":"/foo","value":null}]`, `{"foo":null}`},
+		{`{"foo":"bar"}`, `[{"op":"copy","from":"/foo","path":"/baz"}]`, `{"baz":"bar","foo":"bar"}`},
+		{`[1,2]`, `[{"op":"add","path":"/-","value":3},{"op":"remove","path":"/0"}]`, `[2,3]`},
+		{`{"foo":"bar"}`, `[{"op":"replace","path":"","value":[1]}]`, `[1]`},
+	}
+	for _, test := range tests {
+		patch, err := ParsePatch(test.patch)
+		if err != nil {
+			t.Fatalf("ParsePatch(%s): %v", test.patch, err)
+		}
+		c := Parse(test.doc)
+		if err := c.ApplyPatch(patch); err != nil {
+			t.Errorf("ApplyPatch(%s) on %s: %v", test.patch, test.doc, err)
+			continue
+		}
+		if got := c.String(); got != test.want {
+			t.Errorf("ApplyPatch(%s) on %s = %s, want %s", test.patch, test.doc, got, test.want)
+		}
+	}
+}
+
+func TestApplyPatchErrors(t *testing.T) {
+	tests := []struct {
+		doc, patch string
+	}{
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`},
+		{`{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/2","value":1}]`},
+		{`{"foo":"bar"}`, `[{"op":"remove","path":"/missing"}]`},
+		{`{"foo":"bar"}`, `[{"op":"replace","path":"/missing","value":1}]`},
+		{`{"foo":{"a":1}}`, `[{"op":"move","from":"/foo","path":"/foo/a/b"}]`},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/a","value":1},{"op":"remove","path":"/foo"},{"op":"test","path":"/a","value":2}]`},
+	}
+	for _, test := range tests {
+		patch, err := ParsePatch(test.patch)
+		if err != nil {
+			t.Fatalf("ParsePatch(%s): %v", test.patch, err)
+		}
+		c := Parse(test.doc)
+		if err := c.ApplyPatch(patch); err == nil {
+			t.Errorf("ApplyPatch(%s) on %s succeeded", test.patch, test.doc)
+		}
+		if got := c.String(); got != test.doc {
+			t.Errorf("ApplyPatch(%s) left %s, want %s", test.patch, got, test.doc)
+		}
+	}
+
+	c := Parse(`{"a":1}`)
+	err := c.ApplyPatch(Patch{{Op: "test", Path: "/a", Value: 2}})
+	if !errors.Is(err, ErrTestFailed) {
+		t.Errorf("ApplyPatch(test) = %v, want ErrTestFailed", err)
+	}
+
+	for _, patch := range []string{`{}`, `[{"op":"add","path":"/a"}]`, `[{"op":"jump","path":"/a"}]`, `[{"path":"/a"}]`, `[{"op":"move","path":"/a"}]`} {
+		if _, err := ParsePatch(patch); err == nil {
+			t.Errorf("ParsePatch(%s) succeeded", patch)
+		}
+	}
+}
+
+func TestApplyPatchDocument(t *testing.T) {
+	src := "// settings\n{\n  \"port\": 80, // default\n  \"tags\": [\"a\"]\n}\n"
+	doc, err := ParseDocument(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = doc.ApplyPatch(Patch{{Op: "replace", Path: "/port", Value: 8080}, {Op: "remove", Path: "/missing"}})
+	if err == nil || doc.Source() != src {
+		t.Fatalf("failed ApplyPatch left %q, %v", doc.Source(), err)
+	}
+
+	if err := doc.ApplyPatch(Patch{{Op: "replace", Path: "/port", Value: 8080}, {Op: "add", Path: "/tags/-", Value: "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	want := "// settings\n{\n  \"port\": 8080, // default\n  \"tags\": [\"a\", \"b\"]\n}\n"
+	if doc.Source() != want {
+		t.Errorf("Source() = %q, want %q", doc.Source(), want)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		a, b, want string
+	}{
+		{`{"a":1,"b":[1,2,3],"c":{"d":true}}`, `{"a":1,"b":[1,2,3],"c":{"d":true}}`, `[]`},
+		{`{"a":1,"b":{"c":2}}`, `{"a":2,"d":null}`,
+			`[{"op":"replace","path":"/a","value":2},{"op":"remove","path":"/b"},{"op":"add","path":"/d","value":null}]`},
+		{`{"x":[1,2,3,4]}`, `{"x":[1,5]}`,
+			`[{"op":"replace","path":"/x/1","value":5},{"op":"remove","path":"/x/3"},{"op":"remove","path":"/x/2"}]`},
+		{`{"x~/y":[1]}`, `{"x~/y":[1,{"z":1}]}`, `[{"op":"add","path":"/x~0~1y/-","value":{"z":1}}]`},
+		{`[1]`, `{"a":1}`, `[{"op":"replace","path":"","value":{"a":1}}]`},
+	}
+	for _, test := range tests {
+		a, b := Parse(test.a), Parse(test.b)
+		patch := Diff(a, b)
+		if got := Marshal(patch); got != test.want {
+			t.Errorf("Diff(%s, %s) = %s, want %s", test.a, test.b, got, test.want)
+		}
+
+		parsed, err := ParsePatch(Marshal(patch))
+		if err != nil {
+			t.Fatalf("ParsePatch(%s): %v", Marshal(patch), err)
+		}
+		if err := a.ApplyPatch(parsed); err != nil {
+			t.Fatalf("ApplyPatch(Diff(%s, %s)): %v", test.a, test.b, err)
+		}
+		if a.String() != b.String() {
+			t.Errorf("ApplyPatch(Diff(%s, %s)) = %s", test.a, test.b, a.String())
+		}
+	}
+}
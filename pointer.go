@@ -0,0 +1,84 @@
+package json
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Pointer is a JSON Pointer as defined by RFC 6901, held as its unescaped
+// reference tokens. The empty Pointer refers to the whole document. As a
+// []string it serves as a hierarchy as well, e.g. g.Set(value, ptr...).
+type Pointer []string
+
+// ParsePointer parses the string form of a JSON Pointer, such as "/a~1b/0".
+func ParsePointer(s string) (Pointer, error) {
+	if s == "" {
+		return Pointer{}, nil
+	}
+	if s[0] != '/' {
+		return nil, fmt.Errorf("%w: pointer %q does not start with '/'", ErrInvalidQuery, s)
+	}
+
+	tokens := strings.Split(s[1:], "/")
+	for i, token := range tokens {
+		unescaped, err := UnescapePointerToken(token)
+		if err != nil {
+			return nil, fmt.Errorf("%w: pointer %q: %v", ErrInvalidQuery, s, err)
+		}
+		tokens[i] = unescaped
+	}
+	return Pointer(tokens), nil
+}
+
+// String formats the pointer, escaping its reference tokens.
+func (p Pointer) String() string {
+	var b strings.Builder
+	for _, token := range p {
+		b.WriteByte('/')
+		b.WriteString(EscapePointerToken(token))
+	}
+	return b.String()
+}
+
+// EscapePointerToken escapes '~' as "~0" and '/' as "~1".
+func EscapePointerToken(token string) string {
+	token = strings.ReplaceAll(token, "~", "~0")
+	return strings.ReplaceAll(token, "/", "~1")
+}
+
+// UnescapePointerToken reverses EscapePointerToken. A '~' followed by
+// anything but '0' or '1' is an error.
+func UnescapePointerToken(token string) (string, error) {
+	for i := 0; i < len(token); i++ {
+		if token[i] == '~' && (i+1 == len(token) || token[i+1] != '0' && token[i+1] != '1') {
+			return "", fmt.Errorf("invalid escape in %q", token)
+		}
+	}
+	token = strings.ReplaceAll(token, "~1", "/")
+	return strings.ReplaceAll(token, "~0", "~"), nil
+}
+
+// Get returns the value ptr refers to. Unlike Search it takes no wildcards,
+// and the error names the part of the pointer that could not be resolved.
+func (g *Container) Get(ptr Pointer) (*Container, error) {
+	return g.searchStrict(false, ptr...)
+}
+
+// pointerPrefix returns the pointer to hierarchy[target], for errors.
+func pointerPrefix(hierarchy []string, target int) string {
+	return Pointer(hierarchy[:target+1]).String()
+}
+
+// arrayIndex parses an array index as RFC 6901 writes it: "0", or digits
+// without a leading zero.
+func arrayIndex(seg string) (int, error) {
+	if seg == "" || len(seg) > 1 && seg[0] == '0' || strings.TrimLeft(seg, "0123456789") != "" {
+		return 0, fmt.Errorf("'%v' is not an array index", seg)
+	}
+	index, err := strconv.Atoi(seg)
+	if err != nil {
+		return 0, fmt.Errorf("'%v' is not an array index", seg)
+	}
+	return index, nil
+}
@@ -0,0 +1,117 @@
+package json
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestPointer(t *testing.T) {
+	// The examples of RFC 6901, section 5.
+	c := Parse(`{
+		"foo": ["bar", "baz"],
+		"": 0,
+		"a/b": 1,
+		"c%d": 2,
+		"e^f": 3,
+		"g|h": 4,
+		"i\\j": 5,
+		"k\"l": 6,
+		" ": 7,
+		"m~n": 8
+	}`)
+
+	tests := []struct {
+		pointer string
+		want    string
+	}{
+		{``, Marshal(c.Data())},
+		{`/foo`, `["bar","baz"]`},
+		{`/foo/0`, `"bar"`},
+		{`/`, `0`},
+		{`/a~1b`, `1`},
+		{`/c%d`, `2`},
+		{`/e^f`, `3`},
+		{`/g|h`, `4`},
+		{`/i\j`, `5`},
+		{`/k"l`, `6`},
+		{`/ `, `7`},
+		{`/m~0n`, `8`},
+	}
+	for _, test := range tests {
+		ptr, err := ParsePointer(test.pointer)
+		if err != nil {
+			t.Fatalf("ParsePointer(%q): %v", test.pointer, err)
+		}
+		if ptr.String() != test.pointer {
+			t.Errorf("ParsePointer(%q).String() = %q", test.pointer, ptr.String())
+		}
+
+		value, err := c.Get(ptr)
+		if err != nil {
+			t.Errorf("Get(%q): %v", test.pointer, err)
+			continue
+		}
+		if got := Marshal(value.Data()); got != test.want {
+			t.Errorf("Get(%q) = %s, want %s", test.pointer, got, test.want)
+		}
+	}
+
+	for _, s := range []string{"foo", "/a~2", "/a~"} {
+		if _, err := ParsePointer(s); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("ParsePointer(%q) = %v, want ErrInvalidQuery", s, err)
+		}
+	}
+	if got := (Pointer{"a/b", "~c"}).String(); got != "/a~1b/~0c" {
+		t.Errorf("String() = %q, want %q", got, "/a~1b/~0c")
+	}
+}
+
+func TestPointerErrors(t *testing.T) {
+	c := Parse(`{"a": {"b": [1, {"c": 2}]}}`)
+
+	tests := []struct {
+		pointer string
+		want    string
+	}{
+		{`/a/x/y`, `'/a/x'`},
+		{`/a/b/01`, `'/a/b/01'`},
+		{`/a/b/-`, `'/a/b/-'`},
+		{`/a/b/2`, `'/a/b/2'`},
+		{`/a/b/0/c`, `'/a/b/0/c'`},
+		{`/a/b/1/c~1d`, `'/a/b/1/c~1d'`},
+	}
+	for _, test := range tests {
+		ptr, _ := ParsePointer(test.pointer)
+		_, err := c.Get(ptr)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("Get(%q) = %v, want an error naming %s", test.pointer, err, test.want)
+		}
+	}
+
+	ptr, _ := ParsePointer("/a/b/-")
+	if _, err := c.Set(3, ptr...); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.String(); got != `{"a":{"b":[1,{"c":2},3]}}` {
+		t.Errorf("Set(/a/b/-) = %s", got)
+	}
+}
+
+func TestPathToSlice(t *testing.T) {
+	tests := map[string]string{
+		"/employees/address/countryCode": "[employees address countryCode]",
+		"/a~1b/~0c":                      "[a/b ~c]",
+		"a~1b.~0c":                       "[a.b ~c]",
+		"/":                              "[]",
+	}
+	for path, want := range tests {
+		if got := fmt.Sprint(PathToSlice(path)); got != want {
+			t.Errorf("PathToSlice(%q) = %s, want %s", path, got, want)
+		}
+	}
+	if container.ExistPath("/employees/address/missing") {
+		t.Errorf("ExistPath(/employees/address/missing) = true")
+	}
+}
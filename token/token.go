@@ -5,12 +5,26 @@ import "fmt"
 type Token struct {
 	Type
 	Lit
+	Pos Pos // position of the first character
+	End Pos // position just after the last character; equal to Pos for EOF and implied braces
 }
 
 type Type string
 
 type Lit []rune
 
+// Pos is a position in the input. Offset counts bytes from the start of the
+// input; Line and Column start at 1, and Column counts characters.
+type Pos struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
 // Types
 const (
 	INVALID  = "INVALID"
@@ -23,8 +37,10 @@ const (
 	RBRACKET = "RBRACKET"
 	STRING   = "STRING"
 	INTEGER  = "INTEGER"
+	FLOAT    = "FLOAT"
 	BOOLEAN  = "BOOLEAN"
 	NULL     = "NULL"
+	COMMENT  = "COMMENT"
 )
 
 func NewToken(typ Type, lit string) Token {